@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/acomagu/genpkgbuild-go/pkgbuild"
+)
+
+// writeAURHint writes the commands to commit the PKGBUILD written to
+// pkgbuildPath into the AUR repository, for -aur-hint. .SRCINFO is generated
+// first unless it's written by -srcinfo.
+func writeAURHint(w io.Writer, pkgbuildPath string, data pkgbuild.TmplData, srcinfo bool) {
+	dir := filepath.Dir(pkgbuildPath)
+	name := data.PkgName
+	if data.PkgBase != "" {
+		name = data.PkgBase
+	}
+	version := fmt.Sprintf("%s-%d", data.PkgVer, data.PkgRel)
+	if data.Epoch > 0 {
+		version = fmt.Sprintf("%d:%s", data.Epoch, version)
+	}
+
+	fmt.Fprintln(w, "To publish it to the AUR, run:")
+	fmt.Fprintln(w)
+	if dir != "." {
+		fmt.Fprintf(w, "  cd %s\n", pkgbuild.ShellWord(dir))
+	}
+	if !srcinfo {
+		fmt.Fprintln(w, "  makepkg --printsrcinfo > .SRCINFO")
+	}
+	// The files scaffolded next to the PKGBUILD are in the package too.
+	files := []string{pkgbuild.ShellWord(filepath.Base(pkgbuildPath)), ".SRCINFO"}
+	for _, f := range []string{data.Install, data.Changelog, data.Service} {
+		if f != "" {
+			files = append(files, pkgbuild.ShellWord(f))
+		}
+	}
+	fmt.Fprintf(w, "  git add %s && git commit -m %s\n", strings.Join(files, " "), pkgbuild.ShellWord("upgpkg: "+name+" "+version))
+}
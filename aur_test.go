@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/acomagu/genpkgbuild-go/pkgbuild"
+)
+
+func TestWriteAURHint(t *testing.T) {
+	tests := []struct {
+		name string
+		data pkgbuild.TmplData
+		want string
+	}{
+		{
+			name: "PKGBUILD",
+			data: pkgbuild.TmplData{PkgName: "foo", PkgVer: "1.2.0", PkgRel: 1},
+			want: "  git add PKGBUILD .SRCINFO && git commit -m 'upgpkg: foo 1.2.0-1'\n",
+		},
+		{
+			name: "scaffolded files",
+			data: pkgbuild.TmplData{PkgName: "foo", PkgVer: "1.2.0", PkgRel: 1, Install: "foo.install", Changelog: "my changelog", Service: "foo.service"},
+			want: "  git add PKGBUILD .SRCINFO foo.install 'my changelog' foo.service && git commit -m 'upgpkg: foo 1.2.0-1'\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writeAURHint(&buf, "PKGBUILD", tt.data, true)
+			if got := buf.String(); !strings.HasSuffix(got, tt.want) {
+				t.Errorf("got %q, want the suffix %q", got, tt.want)
+			}
+		})
+	}
+}
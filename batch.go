@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/acomagu/genpkgbuild-go/pkgbuild"
+)
+
+// runBatchFile generates a package for each line of r, which lists the import
+// paths to be installed in the package separated by spaces. The PKGBUILDs are
+// written into the directories named after the packages. Empty lines and the
+// lines starting with "#" are ignored. Up to the number given by -jobs of the
+// packages are generated in parallel.
+func runBatchFile(ctx context.Context, g *pkgbuild.Generator, r io.Reader, opts options) error {
+	var mu sync.Mutex
+	var succeeded, failed int
+	lines := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for line := range lines {
+				output, err := generateInDir(ctx, g, strings.Fields(line), opts)
+
+				mu.Lock()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", line, err)
+					failed++
+				} else {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", line, output)
+					succeeded++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines <- line
+	}
+	close(lines)
+	wg.Wait()
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("could not read the import paths: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "%d succeeded, %d failed\n", succeeded, failed)
+	if failed > 0 {
+		return fmt.Errorf("failed to generate %d of %d packages", failed, succeeded+failed)
+	}
+	return nil
+}
+
+// generateInDir generates the package for the import paths into the directory
+// named after the package, and returns the path of the written file.
+func generateInDir(ctx context.Context, g *pkgbuild.Generator, args []string, opts options) (string, error) {
+	if opts.dryRun {
+		data, err := g.Resolve(ctx, args)
+		if err != nil {
+			return "", err
+		}
+		pkgbuild.PrintData(os.Stderr, data)
+		return "(dry run)", nil
+	}
+
+	var buf bytes.Buffer
+	data, err := g.Generate(ctx, args, &buf)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(data.PkgName, 0755); err != nil {
+		return "", err
+	}
+	output := filepath.Join(data.PkgName, "PKGBUILD")
+	if opts.Format == "json" {
+		output += ".json"
+	}
+	if err := writeFile(output, buf.Bytes(), opts.force); err != nil {
+		return "", err
+	}
+
+	if err := writeCompanionFiles(output, data, opts); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// checkBatchFileOptions returns an error if any option which can't be shared by
+// the packages is given.
+func checkBatchFileOptions(opts options) error {
+	if opts.PkgName != "" || opts.PkgBase != "" || opts.PkgDesc != "" || len(opts.BinNames) > 0 || opts.Tag != "" || opts.RepoRoot != "" || opts.Repo != "" || opts.Dir != "" {
+		return IncorrectUsageError{errors.New("-pkgname, -pkgbase, -pkgdesc, -binname, -tag, -repo-root, -repo and -dir can't be used with -batch-file")}
+	}
+	return nil
+}
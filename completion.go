@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/template"
+)
+
+var completionTmpls = map[string]*template.Template{
+	"bash": template.Must(template.New("bash").Parse(`_genpkgbuild_go_paths() {
+  local gopath="$(go env GOPATH 2>/dev/null)"
+  gopath="${gopath%%:*}"
+  {
+    find "$gopath/pkg/mod" -maxdepth 4 -type d -name '*@*' 2>/dev/null | sed "s|^$gopath/pkg/mod/||;s|@.*||"
+    find "$gopath/src" -mindepth 3 -maxdepth 3 -type d 2>/dev/null | sed "s|^$gopath/src/||"
+  } | sort -u
+}
+
+_genpkgbuild_go() {
+  local cur="${COMP_WORDS[COMP_CWORD]}"
+  if [[ $cur == -* ]]; then
+    COMPREPLY=($(compgen -W "{{range .}}-{{.}} {{end}}" -- "$cur"))
+    return
+  fi
+  COMPREPLY=($(compgen -W "$(_genpkgbuild_go_paths)" -- "$cur"))
+}
+complete -o default -F _genpkgbuild_go genpkgbuild-go
+`)),
+	"zsh": template.Must(template.New("zsh").Parse(`#compdef genpkgbuild-go
+
+_genpkgbuild_go_paths() {
+  local gopath="$(go env GOPATH 2>/dev/null)"
+  gopath="${gopath%%:*}"
+  {
+    find "$gopath/pkg/mod" -maxdepth 4 -type d -name '*@*' 2>/dev/null | sed "s|^$gopath/pkg/mod/||;s|@.*||"
+    find "$gopath/src" -mindepth 3 -maxdepth 3 -type d 2>/dev/null | sed "s|^$gopath/src/||"
+  } | sort -u
+}
+
+_genpkgbuild_go() {
+  if [[ $PREFIX == -* ]]; then
+    compadd -- {{range .}}-{{.}} {{end}}
+    return
+  fi
+  compadd -- ${(f)"$(_genpkgbuild_go_paths)"}
+  _files
+}
+
+compdef _genpkgbuild_go genpkgbuild-go
+`)),
+	"fish": template.Must(template.New("fish").Parse(`function __genpkgbuild_go_paths
+  set -l gopath (string split : (go env GOPATH 2>/dev/null))[1]
+  begin
+    find $gopath/pkg/mod -maxdepth 4 -type d -name '*@*' 2>/dev/null | sed "s|^$gopath/pkg/mod/||;s|@.*||"
+    find $gopath/src -mindepth 3 -maxdepth 3 -type d 2>/dev/null | sed "s|^$gopath/src/||"
+  end | sort -u
+end
+
+{{range . -}}
+complete -c genpkgbuild-go -o {{.}}
+{{end -}}
+complete -c genpkgbuild-go -a '(__genpkgbuild_go_paths)'
+`)),
+}
+
+// writeCompletion writes the completion script for the shell.
+func writeCompletion(w io.Writer, shell string) error {
+	t, ok := completionTmpls[shell]
+	if !ok {
+		return IncorrectUsageError{fmt.Errorf("unknown shell for completion: %s", shell)}
+	}
+
+	var flags []string
+	newFlagSet(&options{}).VisitAll(func(f *flag.Flag) {
+		if f.Name != "completion" {
+			flags = append(flags, f.Name)
+		}
+	})
+
+	return t.Execute(w, flags)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// defaultConfigPath returns the path of the config file, which is at
+// $XDG_CONFIG_HOME/genpkgbuild-go/config.json.
+func defaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "genpkgbuild-go", "config.json"), nil
+}
+
+// loadConfig sets the flags not given in the command line to the values in the
+// config file. The config file is a JSON object mapping the flag names to the
+// values, like {"maintainer": "Name <email>", "license": ["MIT"]}. If the file
+// doesn't exist, nothing is set unless required is true.
+func loadConfig(fs *flag.FlagSet, path string, required bool) error {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(b, &values); err != nil {
+		return fmt.Errorf("could not parse %s: %w", path, err)
+	}
+
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for name, v := range values {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag in %s: %s", path, name)
+		}
+		if given[name] {
+			continue
+		}
+
+		var ss []string
+		switch v := v.(type) {
+		case string:
+			ss = []string{v}
+		case bool:
+			ss = []string{strconv.FormatBool(v)}
+		case float64:
+			ss = []string{strconv.FormatFloat(v, 'f', -1, 64)}
+		case []interface{}:
+			for _, e := range v {
+				s, ok := e.(string)
+				if !ok {
+					return fmt.Errorf("invalid value of %s in %s: %v", name, path, v)
+				}
+				ss = append(ss, s)
+			}
+		default:
+			return fmt.Errorf("invalid value of %s in %s: %v", name, path, v)
+		}
+		for _, s := range ss {
+			if err := fs.Set(name, s); err != nil {
+				return fmt.Errorf("invalid value of %s in %s: %w", name, path, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// editContent lets the user edit b with $EDITOR, through the temp file named
+// name, and returns the edited content. b is returned as is if $EDITOR is not
+// set. Like git commit, it fails if the editor exits with an error.
+func editContent(ctx context.Context, b []byte, name string) ([]byte, error) {
+	editor, err := splitFields(os.Getenv("EDITOR"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid $EDITOR: %w", err)
+	}
+	if len(editor) == 0 {
+		fmt.Fprintln(os.Stderr, "warning: $EDITOR is not set. It's written without editing.")
+		return b, nil
+	}
+
+	dir, err := ioutil.TempDir("", "genpkgbuild")
+	if err != nil {
+		return nil, fmt.Errorf("could not secure a temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	// Keep the name for the syntax highlighting of the editor.
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, editor[0], append(editor[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("the editor failed, and nothing is written: %w", err)
+	}
+
+	edited, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(edited) == 0 {
+		return nil, errors.New("the edited content is empty, and nothing is written")
+	}
+	return edited, nil
+}
@@ -6,5 +6,6 @@ require (
 	github.com/mattn/go-colorable v0.1.2 // indirect
 	github.com/mattn/go-runewidth v0.0.4 // indirect
 	github.com/mattn/go-tty v0.0.0-20190424173100-523744f04859
-	golang.org/x/tools v0.0.0-20190920225731-5eefd052ad72
+	golang.org/x/mod v0.2.0
+	golang.org/x/tools v0.0.0-20191119224855-298f0cb1881e
 )
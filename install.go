@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/acomagu/genpkgbuild-go/pkgbuild"
+)
+
+// installScriptSkeleton is the content of the scaffolded install script.
+const installScriptSkeleton = `post_install() {
+  :
+}
+
+post_upgrade() {
+  :
+}
+
+post_remove() {
+  :
+}
+`
+
+// writeInstallScript scaffolds the install script named name next to the
+// PKGBUILD written to pkgbuildPath. An existing script is left as is, since it
+// may be edited by the user. Nothing is written for STDOUT.
+func writeInstallScript(pkgbuildPath, name string) error {
+	return scaffoldFile(pkgbuildPath, name, installScriptSkeleton)
+}
+
+// scaffoldFile writes the file named name next to the PKGBUILD written to
+// pkgbuildPath, unless it exists. Nothing is written for STDOUT.
+func scaffoldFile(pkgbuildPath, name, content string) error {
+	if pkgbuildPath == "-" {
+		return nil
+	}
+
+	f, err := os.OpenFile(filepath.Join(filepath.Dir(pkgbuildPath), name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprint(f, content)
+	return err
+}
+
+// gitignoreSkeleton is the patterns of the artifacts of makepkg, ignored in the
+// repository of the AUR package.
+const gitignoreSkeleton = `pkg/
+src/
+*.pkg.tar.*
+*.src.tar.*
+*.log
+`
+
+// writeGitignore scaffolds .gitignore of the artifacts of makepkg next to the
+// PKGBUILD written to pkgbuildPath, including the source downloaded by makepkg.
+func writeGitignore(pkgbuildPath string, data pkgbuild.TmplData) error {
+	content := gitignoreSkeleton
+	if i := strings.Index(data.Source, "::"); i >= 0 {
+		content += "/" + data.Source[:i] + "\n"
+	} else {
+		content += "/" + data.Dir + "/\n"
+	}
+	return scaffoldFile(pkgbuildPath, ".gitignore", content)
+}
@@ -1,263 +1,834 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
-	"html/template"
 	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
-	"path"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"time"
+	"unicode"
 
-	"golang.org/x/tools/go/vcs"
+	"github.com/acomagu/genpkgbuild-go/pkgbuild"
 )
 
-type IncorrectUsageError struct {
-	error
-}
+var usage = strings.TrimSpace(`
+Usage: genpkgbuild-go <import-path>... [options]
+
+Specify Go import path as the argument. To install multiple binaries, specify
+an import path for each of them. They must be in the same repository. For the
+import path of a package which is not a command, like the root of the
+repository, the commands in its "cmd" directory are chosen from. A version
+like "@v1.2.3" or "@latest" after the import path packages the release, like
+-tag and -pkgver-from-gomod. A local directory like "./cmd/foo" is read as it
+is checked out, without cloning, with the import path from go.mod and the
+source from the remote "origin" of the git repository.
 
-var pkgVerCmdString = strings.TrimSpace(`
-set -o pipefail
-git describe --long --tags 2>/dev/null | sed 's/\([^-]*-g\)/r\1/;s/-/./g' ||
-printf "r%s.%s" "$(git rev-list --count HEAD)" "$(git rev-parse --short HEAD)"
+e.g. genpkgbuild-go golang.org/x/tools/godoc
+     genpkgbuild-go golang.org/x/tools/cmd/godoc golang.org/x/tools/cmd/guru
+     genpkgbuild-go golang.org/x/tools/cmd/godoc@v0.1.0
+     genpkgbuild-go ./cmd/foo
+
+Options:
+  -o <output>         The output filename. The default is PKGBUILD.
+                      Specify "-" to write STDOUT instead of an actual file.
+                      If it's a directory or ends with "/", like "foo-git/",
+                      the PKGBUILD and the other files are written in it, and
+                      the directory is created if needed.
+  -force              Overwrite the output files if they exist.
+  -edit               Edit the PKGBUILD with $EDITOR before writing it, which
+                      is aborted if the editor fails. It's written as is if
+                      $EDITOR is not set. .SRCINFO by -srcinfo is of the one
+                      before the edit.
+  -format <format>    The output format. "pkgbuild" or "json", which is the
+                      resolved values of the template. The default is pkgbuild.
+  -dry-run            Print the resolved values to STDERR instead of writing
+                      the output.
+  -update <file>      Update pkgver, pkgrel and the source of the existing
+                      PKGBUILD in place, keeping the other lines edited by
+                      the user. The import path is read from url if omitted.
+                      The release package needs the new tag by -tag. pkgrel
+                      is reset to 1 if the version changes, unless -pkgrel is
+                      given. With "-o -", it's written to STDOUT. For a
+                      directory, the PKGBUILD in it is updated.
+  -check-updates <file>
+                      Check whether the version resolved for the existing
+                      PKGBUILD is newer than its pkgver, without writing
+                      anything, like -update. The release package is checked
+                      against the latest module version unless -tag is given.
+                      Exits with 2 if the update is available. For a
+                      directory, the PKGBUILD in it is checked.
+  -pkgname <name>     The package name.
+  -pkgdesc <desc>     The description of the package.
+  -maintainer <who>   The maintainer of the package, in the form of
+                      "Name <email>". The default is read from git config.
+  -contributor <who>  The contributors of the package, repeatable.
+  -depends <pkgs>     The dependent packages, repeatable or comma-separated.
+  -optdepends <dep>   The optional dependency in the form of "pkg: reason",
+                      repeatable.
+  -ldd-depends        Build the binaries with cgo, and suggest the packages
+                      owning the shared libraries linked by them as the
+                      dependencies, with ldd and "pacman -Qo".
+  -mapping <file>     The JSON file mapping the module paths to the packages,
+                      suggested as depends and optdepends for the modules in
+                      go.mod, like {"depends": {"github.com/mattn/go-sqlite3":
+                      "sqlite"}, "optdepends": {"<module>": "pkg: reason"}}.
+  -binname <names>    The binary names to be installed, in the order of the
+                      import paths, or of the commands chosen in their "cmd"
+                      directories. Repeatable or comma-separated.
+  -mode <modes>       The file modes of the binaries, like 0755, in the order
+                      of the import paths. A single mode is for all of them.
+                      Repeatable or comma-separated. The default is 755.
+  -library            Package the source as a library without building any
+                      binary. It's the default if none of the import paths is
+                      a command.
+  -bindir <dir>       The absolute directory the binaries are installed into,
+                      like /usr/lib/foo. The default is /usr/bin.
+  -man <files>        The man pages in the repository to be installed, like
+                      "docs/foo.1". Repeatable or comma-separated.
+  -completions <files>
+                      The shell completion files in the repository to be
+                      installed, like "foo.bash", "_foo" or "foo.fish".
+                      Repeatable or comma-separated.
+  -split              Generate a split package with a package for each binary,
+                      and the one for the man pages named like "foo-docs". The
+                      package name is used as pkgbase unless -pkgbase is given.
+  -pkgbase <name>     The pkgbase of the split package, with -split. The
+                      default is the package name.
+  -license <license>  The licenses, repeatable or comma-separated.
+  -arch <arch>        The architectures, repeatable or comma-separated, like
+                      "x86_64,aarch64". Specify "any" for the package without
+                      binaries. The default is x86_64, or any for a library.
+  -groups <groups>    The pacman groups the package belongs to, repeatable or
+                      comma-separated.
+  -tag <tag>          Package the tagged release using its tarball instead of
+                      the VCS source. Only GitHub and GitLab are supported.
+  -source-zip         With -tag, use the zip of the module in the module proxy
+                      of GOPROXY instead of the tarball. The tag must be the
+                      module version like v1.2.3.
+  -pkgver-from-gomod  Package the release of the latest module version in the
+                      module proxies of GOPROXY, like -tag with it. With -tag,
+                      the tag is checked to be a module version.
+  -no-version         Don't clone the repository to read the version, for the
+                      quick stub. pkgver is the one given by -pkgver, or the
+                      placeholder 0. Like -proxy, the license, the dependencies
+                      and the nested modules are not detected.
+  -pkgver <version>   The pkgver of the package, implying -no-version.
+  -pkgrel <n>         The release number of the package, to be bumped on rebuilds
+                      without version changes. The default is 1.
+  -epoch <n>          The epoch of the package, needed when the versioning scheme
+                      changed. Not rendered if zero.
+  -repo-root <path>   The import path of the root of the repository, for the
+                      case it's not detected correctly. The repository is
+                      fetched from "https://<path>".
+  -repo <url>         The URL of the repository used in url and source instead
+                      of the detected one, like a mirror or a private fork. It
+                      is still built with the original import path.
+  -branch <name>      The branch of the git repository to be packaged, like
+                      "next", instead of the default branch. Not available with
+                      -tag.
+  -commit <hash>      The hash of the git commit the source is pinned to, for
+                      the snapshot. Not available with -tag and -branch.
+  -dir <name>         The directory the repository is cloned into in $srcdir,
+                      i.e. _pkgname. The default is the base name of the
+                      repository root. Not available with -tag. It implies
+                      -named-source.
+  -named-source       Prefix the VCS source with "$_pkgname::", so that it is
+                      cloned into $_pkgname whatever the repository is named.
+                      It's the default if the repository is named differently,
+                      e.g. the mirror by -repo. Not available with -tag.
+  -protocol <proto>   The protocol to fetch the git repository. "https", "git"
+                      or "ssh". The default is https. With ssh, the repository
+                      is cloned with ssh too, authenticated by the SSH agent.
+                      For https, the credentials of git like ~/.netrc are used.
+  -no-cache           Don't use the cached clone of the repository. The git
+                      repositories are cached in $XDG_CACHE_HOME/genpkgbuild-go
+                      by default.
+  -timeout <duration> The time limit of cloning the repository and reading the
+                      version from it, like 30s. 0 means no limit. The default
+                      is 2m.
+  -proxy              Read the version and go.mod from the module proxies in
+                      $GOPROXY instead of cloning the repository, falling back
+                      to cloning if they are not available. The license and the
+                      nested modules are not detected.
+  -go-pkg <pkg>       The package providing the Go toolchain. The default is go.
+  -makedepends <pkgs> The additional build-time dependencies, repeatable or
+                      comma-separated, like "pkg-config,nodejs". The VCS like
+                      git is added for the VCS source.
+  -provides <pkgs>    The virtual packages the package provides, repeatable or
+                      comma-separated. The default is the package name without
+                      the VCS suffix like "-git", for VCS packages.
+  -conflicts <pkgs>   The conflicting packages, repeatable or comma-separated.
+                      The default is the same as -provides.
+  -replaces <pkgs>    The packages replaced by the package, like the old name
+                      of the renamed one. Repeatable or comma-separated.
+  -backup <files>     The config files to be kept on upgrades, relative to "/"
+                      like "etc/foo.conf". Repeatable or comma-separated.
+  -install <file>     The install script like "foo.install", for the hooks like
+                      post_install. The skeleton is written next to the output
+                      unless it exists.
+  -changelog <file>   The changelog like "foo.changelog", shown by "pacman -Qc".
+                      With -scaffold, the changelog of the repository is
+                      copied next to the output unless it exists.
+  -systemd            Write the systemd service running the first binary, like
+                      "foo.service", next to the output unless it exists, and
+                      install it.
+  -systemd-type <type>
+                      The Type= of the systemd service. The default is simple.
+  -systemd-desc <desc>
+                      The Description= of the systemd service. The default is
+                      the description of the package.
+  -ldflags <flags>    The flags passed to "go build -ldflags".
+  -strip              Add "-s -w" to -ldflags for the smaller binaries, without
+                      the symbols and the debug information. It defeats the
+                      debug package of makepkg, e.g. with -guidelines.
+  -version-var <var>  The variable to be set to pkgver, with "-X" flag of the
+                      linker. e.g. main.version
+  -pkgver-cmd <script>
+                      The shell script printing the version, used as the body
+                      of pkgver() and to detect the current version. It runs
+                      in the checked out repository, i.e. "$srcdir/$_pkgname".
+                      The default is based on "git describe" for git.
+  -cgo on|off         Set CGO_ENABLED of "go build" explicitly. By default, it
+                      is left to the environment.
+  -go111module <value>
+                      GO111MODULE of the go commands in the PKGBUILD, like
+                      auto. "none" omits it, since it's on by default for Go
+                      1.16 or later. The default is on.
+  -gotoolchain <value>
+                      GOTOOLCHAIN of the go commands in the PKGBUILD. Specify
+                      "local" not to download the newer toolchain required by
+                      go.mod, for the hermetic builds.
+  -buildflags <flags> The additional flags passed to "go build", split like the
+                      shell does. e.g. '-mod=vendor -tags "netgo osusergo"'
+  -trimpath           Add -trimpath to the flags of "go build", recommended for
+                      the reproducible builds.
+  -mod-download       Download the modules in prepare() into "$srcdir/gopath",
+                      and build with -mod=readonly, not to access the network
+                      in build().
+  -reproducible       Build the release reproducibly, with -trimpath and
+                      -mod-download, i.e. the modules verified by go.sum, and
+                      without the build ID. It requires -tag, -pkgver-from-gomod
+                      or the version of the import path like "@v1.2.3".
+  -submodules         Check out the git submodules in prepare(), fetching them
+                      from the URLs in .gitmodules. Only for the git source,
+                      and not available with -tag, since the archive lacks
+                      them. It's suggested if the repository has .gitmodules.
+  -guidelines         Export GOPATH, GOCACHE, GOFLAGS and the CGO_* flags from
+                      makepkg.conf in build(), following the Go package
+                      guidelines of Arch Linux. The binaries are built as PIE
+                      with the external linker, so cgo must be enabled.
+  -template <file>    Use the template file instead of the built-in one. See
+                      the comment of the built-in template for the variables.
+  -print-template     Print the built-in template to STDOUT and exit, to be
+                      the base of the one for -template.
+  -scaffold           Write .gitignore of the artifacts of makepkg next to the
+                      output, too, unless it exists.
+  -srcinfo            Write .SRCINFO next to the output, too. With "-o -", it
+                      is written to STDOUT following the PKGBUILD.
+  -aur-hint           Print the commands to commit the PKGBUILD and .SRCINFO
+                      to the AUR repository, like "git commit -m 'upgpkg: foo
+                      1.2.3-1'", to STDERR after writing them.
+  -check              Check the syntax of the PKGBUILD with "bash -n", and the
+                      fields with "makepkg --printsrcinfo" if available, before
+                      writing it.
+  -strict             Fail if any import path is not a command, i.e. package
+                      main, instead of warning that no binary is built.
+  -batch, -y          Never ask anything and use the defaults for the values
+                      not given by the flags.
+  -quiet              Don't write the messages like "Please wait..." and the
+                      separator before the output on STDOUT. The questions are
+                      still asked.
+  -verbose, -v        Log each step like the commands run and their outputs to
+                      STDERR.
+  -batch-file <file>  Generate a package for each line of the file, listing
+                      the import paths of the package. The PKGBUILDs are
+                      written into the directories named after the packages.
+                      Specify "-" as the import path to read them from STDIN.
+                      Implies -batch.
+  -jobs <n>           The number of the packages generated in parallel with
+                      -batch-file. The default is 1.
+  -config <file>      Read the defaults of the flags from the file instead of
+                      $XDG_CONFIG_HOME/genpkgbuild-go/config.json. It is a JSON
+                      object mapping the flag names to the values, like
+                      {"maintainer": "Name <email>", "protocol": "ssh"}.
+
+The values not given by the flags are asked interactively.
 `)
 
-var tmpl = template.Must(template.New("PKGBUILD").Parse(`
-{{- /*
-Variables:
-- .PkgName: Required.
-- .Dir:     Required. The directory name which is the destination of "git clone".
-- .PkgVer:  Required.
-- .Repo:    Required. Repository URL.
-- .Root:    Required. The import path corresponding to the root of the repository.
-- .Depends: Optional. The dependencies of this package.
-- .Path:    Optional. The relative import path from the root of the repository.
-- .BinName: Required. The final binary name.
-*/ -}}
-pkgname={{.PkgName}}
-_pkgname={{.Dir}}
-pkgver={{.PkgVer}}
-pkgrel=1
-arch=('i686' 'x86_64')
-url='{{.Repo}}'
-source=('git+git://{{.Root}}')
-depends=({{range $i, $v := .Depends}}{{if $i}} {{end}}'{{.}}'{{end}})
-makedepends=('go')
-sha1sums=('SKIP')
-
-pkgver() {
-  cd "$srcdir/$_pkgname"
-  ( set -o pipefail
-    git describe --long --tags 2>/dev/null | sed 's/\([^-]*-g\)/r\1/;s/-/./g' ||
-    printf "r%s.%s" "$(git rev-list --count HEAD)" "$(git rev-parse --short HEAD)"
-  )
-}
+// listFlag is a flag.Value which accepts being repeated and comma-separated
+// values.
+type listFlag []string
 
-build(){
-  cd "$srcdir/$_pkgname{{if .Path}}/{{.Path}}{{end}}"
-  GO111MODULE=on go build -o "$srcdir/bin/{{.BinName}}"
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
 }
 
-package() {
-  cd "$srcdir/bin"
-  install -Dm755 '{{.BinName}}' "$pkgdir/usr/bin/{{.BinName}}"
+func (l *listFlag) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
 }
-`))
 
-var usage = strings.TrimSpace(`
-Usage: genpkgbuild-go <import-path> [-o <output>]
+// repeatFlag is a flag.Value which can be given multiple times. Unlike
+// listFlag, the value is not split by commas.
+type repeatFlag []string
 
-Specify Go import path as the argument.
+func (r *repeatFlag) String() string {
+	return strings.Join(*r, ",")
+}
 
-e.g. genpkgbuild-go golang.org/x/tools/godoc
+func (r *repeatFlag) Set(v string) error {
+	*r = append(*r, v)
+	return nil
+}
 
-The output filename can be specified with -o flag. The default is PKGBUILD.
-Specify "-" to write STDOUT instead of an actual file.
-`)
+// IncorrectUsageError is the error caused by the invalid flags.
+type IncorrectUsageError struct {
+	error
+}
 
-var scn *bufio.Scanner
-var w io.Writer
-
-type TmplData struct {
-	PkgName string
-	Dir     string
-	PkgVer  string
-	Repo    string
-	Root    string
-	Depends []string
-	Path    string
-	BinName string
+// options is the options of the command, given by the flags.
+type options struct {
+	pkgbuild.Options
+	output     string
+	force      bool
+	dryRun     bool
+	batch      bool
+	verbose    bool
+	batchFile  string
+	jobs       int
+	buildFlags string
+	template   string
+	srcinfo    bool
+	completion string
+	config     string
+	update     string
+	// checkUpdates is the PKGBUILD given by -check-updates.
+	checkUpdates string
+	mapping      string
+	scaffold     bool
+	// printTemplate is -print-template.
+	printTemplate bool
+	edit          bool
+	aurHint       bool
+	// pkgRelGiven is whether -pkgrel is given, not to reset pkgrel with
+	// -update.
+	pkgRelGiven bool
 }
 
-func run() error {
-	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0644)
-	if err != nil {
-		return fmt.Errorf("could not open TTY: %w", err)
+// parseArgs parses argv with fs and returns the positional arguments. Unlike
+// fs.Parse, the flags may be placed after the positional arguments, too. The
+// arguments after "--" are all positional.
+func parseArgs(fs *flag.FlagSet, argv []string) ([]string, error) {
+	var args []string
+	for len(argv) > 0 {
+		if err := fs.Parse(argv); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if n := len(argv) - len(rest); n > 0 && argv[n-1] == "--" {
+			return append(args, rest...), nil
+		}
+		if len(rest) == 0 {
+			break
+		}
+		args = append(args, rest[0])
+		argv = rest[1:]
 	}
-	defer tty.Close()
-	scn = bufio.NewScanner(tty)
-	w = tty
+	return args, nil
+}
 
-	args, outputPath, err := func() ([]string, string, error) {
-		fs := flag.NewFlagSet("", flag.ExitOnError)
-		fs.Usage = func() {
-			fmt.Fprintln(os.Stderr, usage)
-			fmt.Fprintln(os.Stderr)
+// newFlagSet returns the flag set to parse the command line into opts.
+func newFlagSet(opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet("", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		fmt.Fprintln(os.Stderr)
+	}
+	fs.StringVar(&opts.output, "o", "PKGBUILD", "")
+	fs.BoolVar(&opts.force, "force", false, "")
+	fs.BoolVar(&opts.edit, "edit", false, "")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "")
+	fs.StringVar(&opts.Format, "format", "pkgbuild", "")
+	fs.BoolVar(&opts.batch, "batch", false, "")
+	fs.BoolVar(&opts.batch, "y", false, "")
+	fs.BoolVar(&opts.Quiet, "quiet", false, "")
+	fs.BoolVar(&opts.verbose, "verbose", false, "")
+	fs.BoolVar(&opts.verbose, "v", false, "")
+	fs.StringVar(&opts.batchFile, "batch-file", "", "")
+	fs.StringVar(&opts.update, "update", "", "")
+	fs.StringVar(&opts.checkUpdates, "check-updates", "", "")
+	fs.BoolVar(&opts.scaffold, "scaffold", false, "")
+	fs.IntVar(&opts.jobs, "jobs", 1, "")
+	fs.StringVar(&opts.PkgName, "pkgname", "", "")
+	fs.StringVar(&opts.PkgBase, "pkgbase", "", "")
+	fs.StringVar(&opts.PkgDesc, "pkgdesc", "", "")
+	fs.StringVar(&opts.Maintainer, "maintainer", "", "")
+	fs.Var((*listFlag)(&opts.Contributors), "contributor", "")
+	fs.Var((*listFlag)(&opts.Depends), "depends", "")
+	fs.Var((*repeatFlag)(&opts.OptDepends), "optdepends", "")
+	fs.Var((*listFlag)(&opts.Backup), "backup", "")
+	fs.StringVar(&opts.Install, "install", "", "")
+	fs.StringVar(&opts.Changelog, "changelog", "", "")
+	fs.BoolVar(&opts.Systemd, "systemd", false, "")
+	fs.StringVar(&opts.ServiceType, "systemd-type", "simple", "")
+	fs.StringVar(&opts.ServiceDesc, "systemd-desc", "", "")
+	fs.Var((*listFlag)(&opts.ManPages), "man", "")
+	fs.Var((*listFlag)(&opts.Completions), "completions", "")
+	fs.BoolVar(&opts.Split, "split", false, "")
+	fs.Var((*listFlag)(&opts.BinNames), "binname", "")
+	fs.Var((*listFlag)(&opts.Modes), "mode", "")
+	fs.BoolVar(&opts.Library, "library", false, "")
+	fs.Var((*listFlag)(&opts.Licenses), "license", "")
+	fs.Var((*listFlag)(&opts.Arch), "arch", "")
+	fs.Var((*listFlag)(&opts.Groups), "groups", "")
+	fs.StringVar(&opts.Tag, "tag", "", "")
+	fs.StringVar(&opts.RepoRoot, "repo-root", "", "")
+	fs.StringVar(&opts.Repo, "repo", "", "")
+	fs.StringVar(&opts.Dir, "dir", "", "")
+	fs.StringVar(&opts.Branch, "branch", "", "")
+	fs.BoolVar(&opts.NoVersion, "no-version", false, "")
+	fs.StringVar(&opts.PkgVer, "pkgver", "", "")
+	fs.StringVar(&opts.Commit, "commit", "", "")
+	fs.BoolVar(&opts.NamedSource, "named-source", false, "")
+	fs.StringVar(&opts.BinDir, "bindir", "", "")
+	fs.BoolVar(&opts.SourceZip, "source-zip", false, "")
+	fs.BoolVar(&opts.PkgVerFromGoMod, "pkgver-from-gomod", false, "")
+	fs.IntVar(&opts.PkgRel, "pkgrel", 1, "")
+	fs.IntVar(&opts.Epoch, "epoch", 0, "")
+	fs.StringVar(&opts.Protocol, "protocol", "https", "")
+	fs.StringVar(&opts.CGO, "cgo", "", "")
+	fs.StringVar(&opts.GO111Module, "go111module", "on", "")
+	fs.StringVar(&opts.GoToolchain, "gotoolchain", "", "")
+	fs.StringVar(&opts.buildFlags, "buildflags", "", "")
+	fs.BoolVar(&opts.TrimPath, "trimpath", false, "")
+	fs.BoolVar(&opts.ModDownload, "mod-download", false, "")
+	fs.BoolVar(&opts.Reproducible, "reproducible", false, "")
+	fs.BoolVar(&opts.Submodules, "submodules", false, "")
+	fs.BoolVar(&opts.Guidelines, "guidelines", false, "")
+	fs.BoolVar(&opts.NoCache, "no-cache", false, "")
+	fs.DurationVar(&opts.Timeout, "timeout", 2*time.Minute, "")
+	fs.BoolVar(&opts.Proxy, "proxy", false, "")
+	fs.StringVar(&opts.GoPkg, "go-pkg", "go", "")
+	fs.Var((*listFlag)(&opts.MakeDepends), "makedepends", "")
+	fs.Var((*listFlag)(&opts.Provides), "provides", "")
+	fs.Var((*listFlag)(&opts.Conflicts), "conflicts", "")
+	fs.Var((*listFlag)(&opts.Replaces), "replaces", "")
+	fs.StringVar(&opts.LDFlags, "ldflags", "", "")
+	fs.BoolVar(&opts.Strip, "strip", false, "")
+	fs.StringVar(&opts.VersionVar, "version-var", "", "")
+	fs.StringVar(&opts.PkgVerCmd, "pkgver-cmd", "", "")
+	fs.StringVar(&opts.template, "template", "", "")
+	fs.BoolVar(&opts.srcinfo, "srcinfo", false, "")
+	fs.BoolVar(&opts.aurHint, "aur-hint", false, "")
+	fs.BoolVar(&opts.Check, "check", false, "")
+	fs.BoolVar(&opts.Strict, "strict", false, "")
+	fs.BoolVar(&opts.LddDepends, "ldd-depends", false, "")
+	fs.StringVar(&opts.mapping, "mapping", "", "")
+	fs.StringVar(&opts.config, "config", "", "")
+	fs.StringVar(&opts.completion, "completion", "", "")
+	fs.BoolVar(&opts.printTemplate, "print-template", false, "")
+	return fs
+}
+
+func run(ctx context.Context) error {
+	args, opts, err := func() ([]string, options, error) {
+		var opts options
+		fs := newFlagSet(&opts)
+
+		args, err := parseArgs(fs, os.Args[1:])
+		if err != nil {
+			return nil, options{}, IncorrectUsageError{err}
 		}
-		output := fs.String("o", "PKGBUILD", "")
 
-		var args []string
-		fs.Parse(os.Args[1:])
-		for fs.NArg() > 0 {
-			args = append(args, fs.Args()[0])
-			fs.Parse(fs.Args()[1:])
+		// The flags in the command line override the config file.
+		configPath, required := opts.config, true
+		if configPath == "" {
+			configPath, err = defaultConfigPath()
+			required = false
 		}
-		if err := fs.Parse(os.Args[1:]); err != nil {
-			return nil, "", err
+		if err == nil {
+			if err := loadConfig(fs, configPath, required); err != nil {
+				return nil, options{}, fmt.Errorf("could not load the config: %w", err)
+			}
 		}
 
-		return args, *output, nil
+		pkgbuild.SetVerbose(opts.verbose)
+
+		if opts.completion != "" || opts.printTemplate {
+			return args, opts, nil
+		}
+
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "pkgrel" {
+				opts.pkgRelGiven = true
+			}
+		})
+		if opts.update != "" {
+			if opts.batchFile != "" || opts.Format == "json" || opts.srcinfo {
+				return nil, options{}, IncorrectUsageError{errors.New("-batch-file, -format json and -srcinfo can't be used with -update")}
+			}
+			// The values not updated are kept, so nothing is asked.
+			opts.batch = true
+		}
+		if opts.aurHint && (opts.output == "-" || opts.dryRun || opts.batchFile != "" || opts.Format == "json") {
+			return nil, options{}, IncorrectUsageError{errors.New("-aur-hint can't be used with -o -, -dry-run, -batch-file and -format json")}
+		}
+		if opts.edit && (opts.output == "-" || opts.dryRun || opts.batchFile != "" || opts.update != "" || opts.checkUpdates != "") {
+			return nil, options{}, IncorrectUsageError{errors.New("-edit can't be used with -o -, -dry-run, -batch-file, -update and -check-updates")}
+		}
+		if opts.checkUpdates != "" {
+			if opts.update != "" || opts.batchFile != "" || opts.Format == "json" || opts.srcinfo {
+				return nil, options{}, IncorrectUsageError{errors.New("-update, -batch-file, -format json and -srcinfo can't be used with -check-updates")}
+			}
+			opts.batch = true
+		}
+
+		if err := opts.Options.Validate(); err != nil {
+			return nil, options{}, err
+		}
+		if opts.Format == "json" && opts.srcinfo {
+			return nil, options{}, IncorrectUsageError{errors.New("-srcinfo can't be used with -format json")}
+		}
+
+		if opts.jobs < 1 {
+			return nil, options{}, IncorrectUsageError{fmt.Errorf("-jobs must be a positive integer: %d", opts.jobs)}
+		}
+
+		opts.BuildFlags, err = splitFields(opts.buildFlags)
+		if err != nil {
+			return nil, options{}, IncorrectUsageError{fmt.Errorf("invalid -buildflags: %w", err)}
+		}
+
+		for _, a := range opts.Arch {
+			if !knownArchs[a] {
+				fmt.Fprintf(os.Stderr, "warning: unknown architecture: %s\n", a)
+			}
+		}
+		// The import paths may be read from the file, or from url of the
+		// PKGBUILD.
+		if len(args) == 0 && opts.batchFile == "" && opts.update == "" && opts.checkUpdates == "" {
+			return nil, options{}, IncorrectUsageError{errors.New("specify import path")}
+		}
+
+		return args, opts, nil
 	}()
 	if err != nil {
 		return err
 	}
 
-	var output *os.File
-	if outputPath == "-" {
-		output = os.Stdout
-	} else {
-		output, err = os.OpenFile(outputPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
-		if err != nil {
+	if opts.completion != "" {
+		return writeCompletion(os.Stdout, opts.completion)
+	}
+	if opts.printTemplate {
+		_, err := fmt.Fprint(os.Stdout, pkgbuild.DefaultTemplate)
+		return err
+	}
+
+	fromStdin := len(args) == 1 && args[0] == "-"
+	if opts.batchFile != "" || fromStdin {
+		if err := checkBatchFileOptions(opts); err != nil {
 			return err
 		}
+		opts.batch = true
 	}
 
-	if len(os.Args) < 2 {
-		return IncorrectUsageError{errors.New("specify import path")}
+	if opts.mapping != "" {
+		opts.Mapping, err = pkgbuild.LoadMapping(opts.mapping)
+		if err != nil {
+			return fmt.Errorf("could not load the mapping: %w", err)
+		}
 	}
-	importPath := args[0]
-
-	repoRoot, err := vcs.RepoRootForImportPath(importPath, true)
-	if err != nil {
-		return fmt.Errorf("can't get root repo for the import path: %w", err)
+	if opts.template != "" {
+		opts.Template, err = pkgbuild.ParseTemplateFile(opts.template)
+		if err != nil {
+			return fmt.Errorf("could not load the template: %w", err)
+		}
 	}
 
-	if repoRoot.VCS.Name != "Git" {
-		return fmt.Errorf("sorry, not git repo is not supported yet: %s", repoRoot.VCS.Name)
+	var in io.Reader
+	var out io.Writer
+	if !opts.batch {
+		tin, tout, err := openTTY()
+		if err != nil {
+			// Without the terminal, e.g. in containers, ask with STDIN and
+			// STDERR instead.
+			tin, tout = os.Stdin, os.Stderr
+		} else {
+			defer tin.Close()
+			if tout != tin {
+				defer tout.Close()
+			}
+		}
+		in, out = tin, tout
 	}
+	g := pkgbuild.NewGenerator(opts.Options, in, out)
 
-	errC := make(chan error)
-	versionC := make(chan string)
-	go func() {
-		version, err := getVersion(repoRoot)
-		errC <- err
-		versionC <- version
-	}()
-
-	baseName := path.Base(repoRoot.Root)
-	pkgName, err := prompt("Package Name", fmt.Sprintf("%s-git", baseName))
-	if err != nil {
-		return err
+	if fromStdin {
+		return runBatchFile(ctx, g, os.Stdin, opts)
+	}
+	if opts.batchFile != "" {
+		f, err := os.Open(opts.batchFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return runBatchFile(ctx, g, f, opts)
 	}
 
-	dependsList, err := prompt("Dependent Packages(split by space)", "")
-	if err != nil {
-		return err
+	if opts.update != "" {
+		return runUpdate(ctx, g, args, opts)
+	}
+	if opts.checkUpdates != "" {
+		return runCheckUpdates(ctx, g, args, opts)
 	}
-	depends := strings.Fields(dependsList)
 
-	relPath, err := filepath.Rel(repoRoot.Root, importPath)
-	if err != nil {
-		return err
+	// Fail before asking anything if the output can't be written.
+	toStdout := opts.output == "-" || opts.dryRun
+	if !toStdout {
+		opts.output = outputPath(opts.output, opts.Format)
+		if err := checkOverwrite(opts.output, opts.force); err != nil {
+			return err
+		}
 	}
-	if relPath == "." {
-		relPath = ""
+
+	if opts.dryRun {
+		data, err := g.Resolve(ctx, args)
+		if err != nil {
+			return err
+		}
+		pkgbuild.PrintData(os.Stderr, data)
+		return nil
 	}
 
-	binName, err := prompt("Binary name to be installed", path.Base(importPath))
+	var buf bytes.Buffer
+	data, err := g.Generate(ctx, args, &buf)
 	if err != nil {
 		return err
 	}
 
-	fmt.Fprint(w, "Please wait...")
+	if toStdout {
+		if out != nil && !opts.Quiet {
+			fmt.Fprint(out, "===========================\n")
+		}
+		if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	} else {
+		b := buf.Bytes()
+		if opts.edit {
+			b, err = editContent(ctx, b, filepath.Base(opts.output))
+			if err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(opts.output), 0755); err != nil {
+			return err
+		}
+		if err := writeFile(opts.output, b, opts.force); err != nil {
+			return err
+		}
+	}
 
-	if err := <-errC; err != nil {
+	if err := writeCompanionFiles(opts.output, data, opts); err != nil {
 		return err
 	}
-	version := <-versionC
+	if opts.aurHint {
+		writeAURHint(os.Stderr, opts.output, data, opts.srcinfo)
+	}
+	return nil
+}
 
-	if output == os.Stdout {
-		fmt.Fprintln(w, "===========================")
+// writeCompanionFiles writes the files next to the PKGBUILD written to output,
+// like the install script and .SRCINFO.
+func writeCompanionFiles(output string, data pkgbuild.TmplData, opts options) error {
+	if data.Install != "" && opts.Format != "json" {
+		if err := writeInstallScript(output, data.Install); err != nil {
+			return fmt.Errorf("could not write the install script: %w", err)
+		}
+	}
+	if data.Service != "" && opts.Format != "json" {
+		if err := writeServiceUnit(output, data.Service, pkgbuild.ServiceUnit(data, opts.Options)); err != nil {
+			return fmt.Errorf("could not write the systemd service: %w", err)
+		}
+	}
+	if opts.scaffold && opts.Format != "json" {
+		if err := writeGitignore(output, data); err != nil {
+			return fmt.Errorf("could not write .gitignore: %w", err)
+		}
+		if data.Changelog != "" {
+			if err := scaffoldFile(output, data.Changelog, data.UpstreamChangelog); err != nil {
+				return fmt.Errorf("could not write the changelog: %w", err)
+			}
+		}
 	}
-	fmt.Fprintln(w)
 
-	tmpl.Execute(output, TmplData{
-		PkgName: pkgName,
-		Dir:     baseName,
-		PkgVer:  version,
-		Repo:    repoRoot.Repo,
-		Root:    repoRoot.Root,
-		Depends: depends,
-		Path:    relPath,
-		BinName: binName,
-	})
+	if opts.srcinfo {
+		if err := writeSrcinfo(output, data, opts.force); err != nil {
+			return fmt.Errorf("could not write .SRCINFO: %w", err)
+		}
+	}
 	return nil
 }
 
-func prompt(p, dflt string) (string, error) {
-	if dflt != "" {
-		fmt.Fprintf(w, "%s: (%s) ", p, dflt)
-	} else {
-		fmt.Fprintf(w, "%s: ", p)
+// splitFields splits the flags into the words like the shell does, so that
+// the quoted words containing spaces are kept together.
+func splitFields(s string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	var quote rune
+	inField, escaped := false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			field.WriteRune(r)
+			escaped = false
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' {
+				escaped = true
+			} else {
+				field.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inField = true
+		case r == '\\':
+			escaped = true
+			inField = true
+		case unicode.IsSpace(r):
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape: %s", s)
+	}
+	if inField {
+		fields = append(fields, field.String())
 	}
-	if !scn.Scan() {
-		if err := scn.Err(); err != nil {
-			return "", fmt.Errorf("input error: %w", err)
+	return fields, nil
+}
+
+// knownArchs is the architectures supported by Arch Linux and its ports.
+var knownArchs = map[string]bool{
+	"any":      true,
+	"x86_64":   true,
+	"i686":     true,
+	"pentium4": true,
+	"aarch64":  true,
+	"armv7h":   true,
+	"armv6h":   true,
+	"riscv64":  true,
+}
+
+// outputPath returns the path of the output file given by -o. If it's a
+// directory or ends with "/", the file is put in it, like the AUR repositories.
+func outputPath(output, format string) string {
+	if !strings.HasSuffix(output, "/") {
+		if fi, err := os.Stat(output); err != nil || !fi.IsDir() {
+			return output
 		}
-		return "", errors.New("interrupted")
 	}
-	v := strings.TrimSpace(scn.Text())
-	if v == "" {
-		v = dflt
+	name := "PKGBUILD"
+	if format == "json" {
+		name += ".json"
 	}
-	return v, nil
+	return filepath.Join(output, name)
 }
 
-func getVersion(repoRoot *vcs.RepoRoot) (string, error) {
-	dir, err := ioutil.TempDir("", "genpkgbuild")
-	if err != nil {
-		return "", fmt.Errorf("could not secure a temp dir: %w", err)
+// checkOverwrite returns an error if the file already exists, unless force is
+// true.
+func checkOverwrite(name string, force bool) error {
+	if force {
+		return nil
+	}
+	if _, err := os.Lstat(name); err == nil {
+		return IncorrectUsageError{fmt.Errorf("%s already exists. Specify -force to overwrite it", name)}
+	} else if !os.IsNotExist(err) {
+		return err
 	}
-	defer os.RemoveAll(dir)
+	return nil
+}
 
-	if err := repoRoot.VCS.Create(dir, repoRoot.Repo); err != nil {
-		return "", fmt.Errorf("could not clone the repo: %w", err)
+// writeFile writes b to the file atomically: b is written to a temporary file
+// in the same directory, which is renamed to name only if everything is
+// written. Unless force is true, it fails if the file already exists.
+func writeFile(name string, b []byte, force bool) error {
+	if err := checkOverwrite(name, force); err != nil {
+		return err
 	}
 
-	cmd := exec.CommandContext(context.Background(), "bash", "-c", pkgVerCmdString)
-	cmd.Dir = dir
-	version, err := cmd.Output()
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".*")
 	if err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			os.Stderr.Write(exitErr.Stderr)
-		}
-		return "", err
+		return err
 	}
+	tmpName := f.Name()
+	defer os.Remove(tmpName)
 
-	return strings.TrimSpace(string(version)), nil
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, name)
+}
+
+// writeSrcinfo writes .SRCINFO for the PKGBUILD written to pkgbuildPath. If
+// pkgbuildPath is "-", it is written to STDOUT.
+func writeSrcinfo(pkgbuildPath string, data pkgbuild.TmplData, force bool) error {
+	var buf bytes.Buffer
+	if err := pkgbuild.WriteSrcinfo(&buf, data); err != nil {
+		return err
+	}
+
+	if pkgbuildPath == "-" {
+		fmt.Fprintln(os.Stdout)
+		fmt.Fprintln(os.Stdout, "# .SRCINFO")
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return writeFile(filepath.Join(filepath.Dir(pkgbuildPath), ".SRCINFO"), buf.Bytes(), force)
 }
 
 func main() {
-	if err := run(); err != nil {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, os.Interrupt)
+	go func() {
+		<-sigC
+		cancel()
+	}()
+
+	if err := run(ctx); err != nil {
+		if errors.Is(err, errUpdateAvailable) {
+			os.Exit(2)
+		}
 		fmt.Fprintln(os.Stderr, err)
-		if errors.As(err, new(IncorrectUsageError)) {
+		if errors.As(err, new(IncorrectUsageError)) || errors.As(err, new(pkgbuild.IncorrectUsageError)) {
 			fmt.Fprintln(os.Stderr)
 			fmt.Fprintln(os.Stderr, usage)
 			fmt.Fprintln(os.Stderr)
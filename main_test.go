@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		argv    []string
+		args    []string
+		output  string
+		pkgName string
+		force   bool
+	}{
+		{
+			name:   "no flags",
+			argv:   []string{"github.com/a/foo"},
+			args:   []string{"github.com/a/foo"},
+			output: "PKGBUILD",
+		},
+		{
+			name:    "flags before",
+			argv:    []string{"-o", "-", "-pkgname", "foo", "github.com/a/foo"},
+			args:    []string{"github.com/a/foo"},
+			output:  "-",
+			pkgName: "foo",
+		},
+		{
+			name:    "flags after",
+			argv:    []string{"github.com/a/foo", "-o", "-", "-force", "-pkgname=foo"},
+			args:    []string{"github.com/a/foo"},
+			output:  "-",
+			pkgName: "foo",
+			force:   true,
+		},
+		{
+			name:   "flags between",
+			argv:   []string{"github.com/a/foo", "-force", "github.com/a/bar", "-o", "-"},
+			args:   []string{"github.com/a/foo", "github.com/a/bar"},
+			output: "-",
+			force:  true,
+		},
+		{
+			name:   "double dash",
+			argv:   []string{"-force", "--", "github.com/a/foo", "-o", "-"},
+			args:   []string{"github.com/a/foo", "-o", "-"},
+			output: "PKGBUILD",
+			force:  true,
+		},
+		{
+			name:   "double dash after the argument",
+			argv:   []string{"github.com/a/foo", "--", "-force"},
+			args:   []string{"github.com/a/foo", "-force"},
+			output: "PKGBUILD",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts options
+			args, err := parseArgs(newFlagSet(&opts), tt.argv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args %q, want %q", args, tt.args)
+			}
+			if opts.output != tt.output || opts.PkgName != tt.pkgName || opts.force != tt.force {
+				t.Errorf("-o %q -pkgname %q -force %v, want -o %q -pkgname %q -force %v", opts.output, opts.PkgName, opts.force, tt.output, tt.pkgName, tt.force)
+			}
+		})
+	}
+}
+
+func TestRunNoArgs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genpkgbuild-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	// Not to read the config of the user.
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	defer func(args []string) { os.Args = args }(os.Args)
+
+	for _, argv := range [][]string{
+		{"genpkgbuild-go"},
+		{"genpkgbuild-go", "-y", "-force", "-o", "PKGBUILD", "-srcinfo"},
+	} {
+		os.Args = argv
+		err := run(context.Background())
+		if !errors.As(err, &IncorrectUsageError{}) {
+			t.Errorf("%q: got %v, want IncorrectUsageError", argv, err)
+		}
+		fis, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, fi := range fis {
+			t.Errorf("%q: %s is left", argv, fi.Name())
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package pkgbuild
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/tools/go/vcs"
+)
+
+// cacheLocks holds a *sync.Mutex for each cached clone, not to be used by the
+// generations in parallel at once.
+var cacheLocks sync.Map
+
+// cachedClone returns the directory of the clone of the git repository in the
+// cache, which is at $XDG_CACHE_HOME/genpkgbuild-go, cloned by r. If the clone
+// already exists, it is updated to the latest default branch with "git fetch"
+// instead of cloning again. The clone is locked until release is called, unless
+// err is returned.
+func cachedClone(ctx context.Context, r runner, repoRoot *vcs.RepoRoot) (dir string, release func(), err error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", nil, fmt.Errorf("could not find the cache directory: %w", err)
+	}
+	dir = filepath.Join(cacheDir, "genpkgbuild-go", url.QueryEscape(repoRoot.Repo))
+
+	m, _ := cacheLocks.LoadOrStore(dir, new(sync.Mutex))
+	mu := m.(*sync.Mutex)
+	mu.Lock()
+	defer func() {
+		if err != nil {
+			mu.Unlock()
+		}
+	}()
+
+	logger.debugf("the cached clone: %s", dir)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return "", nil, fmt.Errorf("could not create the cache directory: %w", err)
+		}
+		if err := (gitBackend{}).clone(ctx, r, repoRoot.VCS, repoRoot.Repo, dir); err != nil {
+			os.RemoveAll(dir)
+			return "", nil, fmt.Errorf("could not clone the repo: %w", err)
+		}
+		return dir, mu.Unlock, nil
+	}
+
+	for _, cmdline := range []string{
+		"fetch --prune --tags --force origin",
+		"checkout --force --detach origin/HEAD",
+		"clean -d --force -x",
+	} {
+		if err := r.runVCS(ctx, repoRoot.VCS, dir, cmdline); err != nil {
+			return "", nil, fmt.Errorf("could not update the cached clone: %w", err)
+		}
+	}
+	return dir, mu.Unlock, nil
+}
@@ -0,0 +1,30 @@
+package pkgbuild
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// changelogFileNames are the names of the changelog files looked for at the
+// root of the repository, in the order of the preference.
+var changelogFileNames = []string{
+	"CHANGELOG", "CHANGELOG.md", "ChangeLog", "CHANGES", "CHANGES.md",
+	"HISTORY.md", "NEWS", "NEWS.md",
+}
+
+// readChangelog returns the content of the changelog in dir, or "" if it's not
+// found.
+func readChangelog(dir string) (string, error) {
+	for _, name := range changelogFileNames {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,72 @@
+package pkgbuild
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// checkPKGBUILD checks the syntax of the PKGBUILD with "bash -n", and if
+// makepkg is available, validates the fields with "makepkg --printsrcinfo".
+// The diagnostics are written to STDERR. The files named by companions, like
+// install= and changelog=, are written with the PKGBUILD, since makepkg checks
+// they exist.
+func checkPKGBUILD(ctx context.Context, pkgbuild []byte, companions ...string) error {
+	logger.debugf("running: bash -n")
+	cmd := exec.CommandContext(ctx, "bash", "-n")
+	cmd.Stdin = bytes.NewReader(pkgbuild)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Stderr.Write(out)
+		return fmt.Errorf("the syntax check of PKGBUILD failed: %w", err)
+	}
+
+	if _, err := exec.LookPath("makepkg"); err != nil {
+		return nil
+	}
+
+	dir, err := prepareCheckDir(pkgbuild, companions)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	logger.debugf("running in %s: makepkg --printsrcinfo", dir)
+	cmd = exec.CommandContext(ctx, "makepkg", "--printsrcinfo")
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Stderr.Write(stderr.Bytes())
+		return fmt.Errorf("makepkg --printsrcinfo failed: %w", err)
+	}
+	os.Stderr.Write(stderr.Bytes())
+	return nil
+}
+
+// prepareCheckDir returns the temp dir with the PKGBUILD and the empty files
+// named by companions, standing for the ones scaffolded next to the PKGBUILD
+// after the check. The names are the file names checked by Options.Validate.
+func prepareCheckDir(pkgbuild []byte, companions []string) (string, error) {
+	dir, err := ioutil.TempDir("", "genpkgbuild")
+	if err != nil {
+		return "", fmt.Errorf("could not secure a temp dir: %w", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "PKGBUILD"), pkgbuild, 0644); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	for _, name := range companions {
+		if name == "" {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
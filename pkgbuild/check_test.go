@@ -0,0 +1,31 @@
+package pkgbuild
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrepareCheckDir(t *testing.T) {
+	dir, err := prepareCheckDir([]byte("pkgname=foo\ninstall=foo.install\n"), []string{"foo.install", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	if len(names) != 2 || names[0] != "PKGBUILD" || names[1] != "foo.install" {
+		t.Errorf("got %q, want PKGBUILD and foo.install", names)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "PKGBUILD")); err != nil || string(b) != "pkgname=foo\ninstall=foo.install\n" {
+		t.Errorf("PKGBUILD: %q, %v", b, err)
+	}
+}
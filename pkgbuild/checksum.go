@@ -0,0 +1,71 @@
+package pkgbuild
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// sourceSHA256 returns the SHA-256 checksum of the source, given in the syntax
+// of the source array of PKGBUILD. It returns "SKIP" for the VCS sources.
+func sourceSHA256(ctx context.Context, source string) (string, error) {
+	url := source
+	if i := strings.Index(source, "::"); i >= 0 {
+		url = source[i+2:]
+	}
+	// The sources fetched with the VCS, like "git+https://...", are not
+	// checksummed.
+	for name := range vcsBackends {
+		if strings.HasPrefix(url, name+"+") || strings.HasPrefix(url, name+"://") {
+			return "SKIP", nil
+		}
+	}
+
+	f, err := ioutil.TempFile("", "genpkgbuild")
+	if err != nil {
+		return "", fmt.Errorf("could not create a temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := download(ctx, f, url); err != nil {
+		return "", err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func download(ctx context.Context, w io.Writer, url string) error {
+	logger.debugf("GET %s", url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not download %s: %s", url, resp.Status)
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("could not download %s: %w", url, err)
+	}
+	return nil
+}
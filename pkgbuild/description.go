@@ -0,0 +1,47 @@
+package pkgbuild
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// fetchDescription fetches the description of the repository from the API of
+// the host. It returns "" if the host is not supported.
+func fetchDescription(ctx context.Context, root string) (string, error) {
+	var apiURL string
+	switch {
+	case strings.HasPrefix(root, "github.com/"):
+		apiURL = fmt.Sprintf("https://api.github.com/repos/%s", strings.TrimPrefix(root, "github.com/"))
+	case strings.HasPrefix(root, "gitlab.com/"):
+		apiURL = fmt.Sprintf("https://gitlab.com/api/v4/projects/%s", url.PathEscape(strings.TrimPrefix(root, "gitlab.com/")))
+	default:
+		return "", nil
+	}
+
+	logger.debugf("GET %s", apiURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not fetch %s: %s", apiURL, resp.Status)
+	}
+
+	var repo struct {
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+		return "", fmt.Errorf("could not decode the response from %s: %w", apiURL, err)
+	}
+	return strings.TrimSpace(repo.Description), nil
+}
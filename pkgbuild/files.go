@@ -0,0 +1,54 @@
+package pkgbuild
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// InstallFile is a file in the repository to be installed by package().
+type InstallFile struct {
+	// Src is the relative path from the root of the repository.
+	Src string `json:"src"`
+	// Dest is the absolute path in the installed system.
+	Dest string `json:"dest"`
+}
+
+// manPageFile returns the destination of the man page at src. The section is
+// taken from the extension, like "foo.1".
+func manPageFile(src string) (InstallFile, error) {
+	base := strings.TrimSuffix(path.Base(src), ".gz")
+	ext := path.Ext(base)
+	if len(ext) < 2 || ext[1] < '1' || '9' < ext[1] {
+		return InstallFile{}, fmt.Errorf("can't tell the section of the man page from the extension: %s", src)
+	}
+	return InstallFile{
+		Src:  src,
+		Dest: fmt.Sprintf("/usr/share/man/man%c/%s", ext[1], path.Base(src)),
+	}, nil
+}
+
+// completionFile returns the destination of the completion file at src. The
+// shell is taken from the extension, like "foo.bash", "foo.zsh", "foo.fish",
+// or the name for zsh, like "_foo".
+func completionFile(src string) (InstallFile, error) {
+	base := path.Base(src)
+	ext := path.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+
+	var dest string
+	switch {
+	case ext == ".bash":
+		dest = "/usr/share/bash-completion/completions/" + name
+	case ext == ".zsh" || strings.HasPrefix(base, "_"):
+		dest = "/usr/share/zsh/site-functions/_" + strings.TrimPrefix(name, "_")
+	case ext == ".fish":
+		dest = "/usr/share/fish/vendor_completions.d/" + base
+	default:
+		return InstallFile{}, fmt.Errorf("can't tell the shell of the completion file from the name: %s", src)
+	}
+	return InstallFile{
+		Src:  src,
+		Dest: dest,
+	}, nil
+}
@@ -0,0 +1,158 @@
+package pkgbuild
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"text/template"
+)
+
+// Generator generates the PKGBUILD for the import paths with the options,
+// asking the values not given by them to the user with scn and w. In batch
+// mode, scn is nil and nothing is asked, so that it can be shared by the
+// generations in parallel.
+type Generator struct {
+	scn  *bufio.Scanner
+	w    io.Writer
+	opts Options
+	tmpl *template.Template
+	// runner runs the VCS and the script of pkgver.
+	runner runner
+}
+
+// NewGenerator returns the Generator with opts. The values are asked with in
+// and w, or the defaults are taken without asking if in is nil.
+func NewGenerator(opts Options, in io.Reader, w io.Writer) *Generator {
+	if opts.PkgRel == 0 {
+		opts.PkgRel = 1
+	}
+	if opts.Protocol == "" {
+		opts.Protocol = "https"
+	}
+	if opts.GO111Module == "" {
+		opts.GO111Module = "on"
+	}
+	if opts.GoPkg == "" {
+		opts.GoPkg = "go"
+	}
+	if opts.ServiceType == "" {
+		opts.ServiceType = "simple"
+	}
+	t := opts.Template
+	if t == nil {
+		t = tmpl
+	}
+	g := &Generator{w: w, opts: opts, tmpl: t, runner: execRunner{}}
+	if in != nil {
+		g.scn = bufio.NewScanner(in)
+	}
+	if w == nil {
+		g.w = ioutil.Discard
+	}
+	return g
+}
+
+// Generate writes the PKGBUILD for the import paths to w with opts, taking the
+// defaults for the values not given without asking anything.
+func Generate(ctx context.Context, opts Options, importPaths []string, w io.Writer) error {
+	_, err := NewGenerator(opts, nil, nil).Generate(ctx, importPaths, w)
+	return err
+}
+
+// GenerateToString returns the PKGBUILD for the import paths with opts, like
+// Generate.
+func GenerateToString(ctx context.Context, opts Options, importPaths []string) (string, error) {
+	var buf bytes.Buffer
+	if err := Generate(ctx, opts, importPaths, &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Generate writes the PKGBUILD for the import paths to out, or the resolved
+// values in JSON with -format json. The values are returned to write the other
+// files, like .SRCINFO.
+func (g *Generator) Generate(ctx context.Context, importPaths []string, out io.Writer) (TmplData, error) {
+	data, err := g.resolveData(ctx, importPaths)
+	if err != nil {
+		return TmplData{}, err
+	}
+
+	var buf bytes.Buffer
+	if g.opts.Format == "json" {
+		if err := writeJSON(&buf, data); err != nil {
+			return TmplData{}, err
+		}
+	} else {
+		if err := g.tmpl.Execute(&buf, data); err != nil {
+			return TmplData{}, fmt.Errorf("could not render the PKGBUILD: %w", err)
+		}
+		if g.opts.Check {
+			if err := checkPKGBUILD(ctx, buf.Bytes(), data.Install, data.Changelog); err != nil {
+				return TmplData{}, err
+			}
+		}
+	}
+	if _, err := out.Write(buf.Bytes()); err != nil {
+		return TmplData{}, err
+	}
+	return data, nil
+}
+
+// Resolve returns the values of the PKGBUILD for the import paths, without
+// rendering it.
+func (g *Generator) Resolve(ctx context.Context, importPaths []string) (TmplData, error) {
+	return g.resolveData(ctx, importPaths)
+}
+
+// notice writes the cosmetic message like "Please wait..." to the user, unless
+// -quiet is given.
+func (g *Generator) notice(s string) {
+	if !g.opts.Quiet {
+		fmt.Fprint(g.w, s)
+	}
+}
+
+// prompt asks p to the user and returns the answer, or dflt if the answer is
+// empty or the input reaches EOF. In batch mode, it returns dflt without asking
+// anything.
+func (g *Generator) prompt(ctx context.Context, p, dflt string) (string, error) {
+	if g.scn == nil {
+		return dflt, nil
+	}
+	if dflt != "" {
+		fmt.Fprintf(g.w, "%s: (%s) ", p, dflt)
+	} else {
+		fmt.Fprintf(g.w, "%s: ", p)
+	}
+	// Scan in another goroutine not to block the cancellation by Ctrl-C.
+	scanC := make(chan bool, 1)
+	go func() {
+		scanC <- g.scn.Scan()
+	}()
+	var ok bool
+	select {
+	case <-ctx.Done():
+		fmt.Fprintln(g.w)
+		return "", errors.New("interrupted")
+	case ok = <-scanC:
+	}
+	if !ok {
+		if err := g.scn.Err(); err != nil {
+			return "", fmt.Errorf("input error: %w", err)
+		}
+		// On EOF, e.g. of the piped input, take the defaults for the rest.
+		fmt.Fprintln(g.w)
+		return dflt, nil
+	}
+	v := strings.TrimSpace(g.scn.Text())
+	if v == "" {
+		v = dflt
+	}
+	return v, nil
+}
@@ -0,0 +1,205 @@
+package pkgbuild
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// newLocalModule returns the temp git repository committing the files, to be
+// given as the local directory without the network. It's removed by the
+// returned function.
+func newLocalModule(t *testing.T, files map[string]string) (string, func()) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "genpkgbuild-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The symlinks like /tmp on macOS are resolved as git does.
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFiles(dir, files); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestGenerateTemplateError(t *testing.T) {
+	dir, cleanup := newLocalModule(t, map[string]string{
+		"go.mod":          "module example.com/foo\n",
+		"cmd/foo/main.go": "package main\n\nfunc main() {}\n",
+	})
+	defer cleanup()
+
+	// It fails at the execution, not at the parsing.
+	tmpl := template.Must(template.New("PKGBUILD").Parse("pkgname={{.PkgName}}\n{{index .Binaries 5}}\n"))
+	var buf bytes.Buffer
+	err := Generate(context.Background(), Options{Template: tmpl, Quiet: true}, []string{filepath.Join(dir, "cmd", "foo")}, &buf)
+	if err == nil || !strings.Contains(err.Error(), "could not render the PKGBUILD") {
+		t.Errorf("got %v, want the error of the template", err)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("the partial output is written: %q", buf.String())
+	}
+}
+
+func TestGenerateInvalidOptions(t *testing.T) {
+	// The options are checked before the repository is looked up.
+	opts := Options{Epoch: -1, PkgRel: -3, Backup: []string{"/etc/x"}, Dir: "a b/c", Quiet: true}
+	_, err := GenerateToString(context.Background(), opts, []string{"example.com/foo"})
+	if !errors.As(err, new(IncorrectUsageError)) {
+		t.Errorf("got %v, want IncorrectUsageError", err)
+	}
+}
+
+func TestGenerateCheckInstall(t *testing.T) {
+	if _, err := exec.LookPath("makepkg"); err != nil {
+		t.Skip("makepkg is not installed")
+	}
+	dir, cleanup := newLocalModule(t, map[string]string{
+		"go.mod":          "module example.com/foo\n",
+		"cmd/foo/main.go": "package main\n\nfunc main() {}\n",
+	})
+	defer cleanup()
+
+	// The install script and the changelog are not written yet at the check.
+	opts := Options{Check: true, Install: "foo.install", Changelog: "changelog", Quiet: true}
+	if _, err := GenerateToString(context.Background(), opts, []string{filepath.Join(dir, "cmd", "foo")}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGenerateNotCommand(t *testing.T) {
+	dir, cleanup := newLocalModule(t, map[string]string{
+		"go.mod":          "module example.com/foo\n",
+		"cmd/foo/main.go": "package main\n\nfunc main() {}\n",
+		"lib/lib.go":      "package lib\n",
+	})
+	defer cleanup()
+	// The library is given with the command, so that it's not packaged as
+	// the library.
+	args := []string{filepath.Join(dir, "cmd", "foo"), filepath.Join(dir, "lib")}
+
+	var log bytes.Buffer
+	defer func(w io.Writer) { logger.w = w }(logger.w)
+	logger.w = &log
+
+	if _, err := GenerateToString(context.Background(), Options{Quiet: true}, args); err != nil {
+		t.Fatal(err)
+	}
+	if want := "warning: example.com/foo/lib is not package main, so no binary is built for it"; !strings.Contains(log.String(), want) {
+		t.Errorf("the log %q doesn't contain %q", log.String(), want)
+	}
+
+	_, err := GenerateToString(context.Background(), Options{Quiet: true, Strict: true}, args)
+	if err == nil || !strings.Contains(err.Error(), "is not package main") {
+		t.Errorf("got %v with Strict, want the error", err)
+	}
+}
+
+func TestGenerateLibraryOnly(t *testing.T) {
+	dir, cleanup := newLocalModule(t, map[string]string{
+		"go.mod":     "module example.com/foo\n",
+		"lib/lib.go": "package lib\n",
+	})
+	defer cleanup()
+
+	var log bytes.Buffer
+	defer func(w io.Writer) { logger.w = w }(logger.w)
+	logger.w = &log
+
+	// Only the library is given, so it's packaged as the library instead of
+	// warning, even with Strict.
+	out, err := GenerateToString(context.Background(), Options{Quiet: true, Strict: true}, []string{filepath.Join(dir, "lib")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "go build") || strings.Contains(log.String(), "warning:") {
+		t.Errorf("the library is built:\n%s\n%s", out, log.String())
+	}
+	if want := "packaged as a library"; !strings.Contains(log.String(), want) {
+		t.Errorf("the log %q doesn't contain %q", log.String(), want)
+	}
+}
+
+func TestResolveMirrorNamedSource(t *testing.T) {
+	dir, cleanup := newLocalModule(t, map[string]string{
+		"go.mod":          "module example.com/foo\n",
+		"cmd/foo/main.go": "package main\n\nfunc main() {}\n",
+	})
+	defer cleanup()
+
+	tests := []struct {
+		repo  string
+		named bool
+	}{
+		{"", false},
+		{"https://example.com/mirrors/foo.git", false},
+		{"https://example.com/mirrors/Foo-mirror.git", true},
+	}
+	for _, tt := range tests {
+		g := NewGenerator(Options{Quiet: true, Repo: tt.repo}, nil, nil)
+		data, err := g.Resolve(context.Background(), []string{filepath.Join(dir, "cmd", "foo")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data.NamedSource != tt.named || data.Dir != "foo" {
+			t.Errorf("-repo %q: NamedSource %v, Dir %q of the source %q, want %v", tt.repo, data.NamedSource, data.Dir, data.Source, tt.named)
+		}
+	}
+}
+
+func TestResolveBinNamesOfCommands(t *testing.T) {
+	dir, cleanup := newLocalModule(t, map[string]string{
+		"go.mod":          "module example.com/foo\n",
+		"foo.go":          "package foo\n",
+		"cmd/bar/main.go": "package main\n\nfunc main() {}\n",
+		"cmd/foo/main.go": "package main\n\nfunc main() {}\n",
+	})
+	defer cleanup()
+
+	// The root is replaced by the commands in cmd.
+	g := NewGenerator(Options{Quiet: true, BinNames: []string{"x", "y"}}, nil, nil)
+	data, err := g.Resolve(context.Background(), []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, b := range data.Binaries {
+		got = append(got, b.Name+" "+b.Path)
+	}
+	if want := []string{"x cmd/bar", "y cmd/foo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	g = NewGenerator(Options{Quiet: true, BinNames: []string{"x", "y", "z"}}, nil, nil)
+	if _, err := g.Resolve(context.Background(), []string{dir}); !errors.As(err, &IncorrectUsageError{}) {
+		t.Errorf("got %v for the extra name, want IncorrectUsageError", err)
+	}
+}
@@ -0,0 +1,225 @@
+package pkgbuild
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// knownDepends maps the module paths to the Arch packages needed at runtime
+// by the programs using them. A module path also matches its sub-modules.
+var knownDepends = map[string]string{
+	"github.com/mattn/go-sqlite3":      "sqlite",
+	"github.com/gotk3/gotk3":           "gtk3",
+	"github.com/mattn/go-gtk":          "gtk2",
+	"github.com/libgit2/git2go":        "libgit2",
+	"github.com/veandco/go-sdl2":       "sdl2",
+	"github.com/go-gl/glfw":            "glfw",
+	"github.com/google/gopacket":       "libpcap",
+	"github.com/therecipe/qt":          "qt5-base",
+	"github.com/gordonklaus/portaudio": "portaudio",
+	"github.com/hajimehoshi/oto":       "alsa-lib",
+}
+
+// goModDepends is the dependencies suggested from the modules required in
+// go.mod.
+type goModDepends struct {
+	// depends and optDepends is the Arch packages mapped from the modules.
+	depends    []string
+	optDepends []string
+	// unknown is the modules with no known package.
+	unknown []string
+}
+
+// readGoModDepends reads go.mod in dir and returns the Arch packages mapped
+// from the required modules by m and knownDepends. It returns nothing if there
+// is no go.mod.
+func readGoModDepends(dir string, m Mapping) (goModDepends, error) {
+	p := filepath.Join(dir, "go.mod")
+	b, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return goModDepends{}, nil
+	}
+	if err != nil {
+		return goModDepends{}, err
+	}
+	return parseGoModDepends(p, b, m)
+}
+
+// parseGoModDepends is like readGoModDepends, but parses the content of go.mod
+// given as b. The file name is used in the errors.
+func parseGoModDepends(file string, b []byte, m Mapping) (goModDepends, error) {
+	f, err := modfile.ParseLax(file, b, nil)
+	if err != nil {
+		return goModDepends{}, err
+	}
+
+	var ds goModDepends
+	for _, r := range f.Require {
+		pkg, ok := lookupModule(m.Depends, r.Mod.Path)
+		if !ok {
+			pkg, ok = lookupModule(knownDepends, r.Mod.Path)
+		}
+		if ok {
+			ds.depends = appendUnique(ds.depends, pkg)
+		}
+		if d, optOK := lookupModule(m.OptDepends, r.Mod.Path); optOK {
+			ds.optDepends = appendUnique(ds.optDepends, d)
+			ok = true
+		}
+		if !ok {
+			ds.unknown = append(ds.unknown, r.Mod.Path)
+		}
+	}
+	return ds, nil
+}
+
+// lookupModule returns the value for the longest module path in m matching
+// modPath.
+func lookupModule(m map[string]string, modPath string) (string, bool) {
+	var found, v string
+	for p, pkg := range m {
+		if (modPath == p || strings.HasPrefix(modPath, p+"/")) && len(p) > len(found) {
+			found, v = p, pkg
+		}
+	}
+	return v, found != ""
+}
+
+func appendUnique(ss []string, s string) []string {
+	for _, v := range ss {
+		if v == s {
+			return ss
+		}
+	}
+	return append(ss, s)
+}
+
+// sortUnique returns the sorted ss without the duplicates.
+func sortUnique(ss []string) []string {
+	var u []string
+	for _, s := range ss {
+		u = appendUnique(u, s)
+	}
+	sort.Strings(u)
+	return u
+}
+
+// readModulePath returns the module path declared in go.mod in dir. It returns
+// "" if there is no go.mod.
+func readModulePath(dir string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return modfile.ModulePath(b), nil
+}
+
+// findModuleDir returns the nearest directory containing go.mod, going up from
+// rel to the root, as the relative path from root. It reports false if there is
+// no go.mod.
+func findModuleDir(root, rel string) (string, bool, error) {
+	for d := rel; ; d = path.Dir(d) {
+		if d == "." {
+			d = ""
+		}
+		_, err := os.Stat(filepath.Join(root, filepath.FromSlash(d), "go.mod"))
+		if err == nil {
+			return d, true, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", false, err
+		}
+		if d == "" {
+			return "", false, nil
+		}
+	}
+}
+
+// isMainPackage reports whether the Go files in dir, except the tests, are all
+// in package main.
+func isMainPackage(dir string) (bool, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	fset := token.NewFileSet()
+	found := false
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.PackageClauseOnly)
+		if err != nil {
+			return false, err
+		}
+		if f.Name.Name != "main" {
+			return false, nil
+		}
+		found = true
+	}
+	return found, nil
+}
+
+// findCommands returns the directories of package main under "cmd" in the
+// directory rel, relative to root.
+func findCommands(root, rel string) ([]string, error) {
+	fis, err := ioutil.ReadDir(filepath.Join(root, filepath.FromSlash(rel), "cmd"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cmds []string
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+		c := path.Join(rel, "cmd", fi.Name())
+		ok, err := isMainPackage(filepath.Join(root, filepath.FromSlash(c)))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			cmds = append(cmds, c)
+		}
+	}
+	return cmds, nil
+}
+
+// splitMajorVersion splits the major version suffix like "v2" off the import
+// path. It returns the import path as is and "" if there is no suffix.
+func splitMajorVersion(importPath string) (prefix string, major string) {
+	dir, elem := path.Split(importPath)
+	if dir == "" || !isMajorVersion(elem) {
+		return importPath, ""
+	}
+	return strings.TrimSuffix(dir, "/"), elem
+}
+
+// isMajorVersion reports whether s is a major version suffix of module paths,
+// i.e. "v2", "v3", and so on.
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' || s[1] == '0' || s == "v1" {
+		return false
+	}
+	for _, c := range s[1:] {
+		if c < '0' || '9' < c {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,26 @@
+package pkgbuild
+
+import "testing"
+
+func TestSplitMajorVersion(t *testing.T) {
+	tests := []struct {
+		importPath    string
+		prefix, major string
+	}{
+		{"github.com/a/foo", "github.com/a/foo", ""},
+		{"github.com/a/foo/v2", "github.com/a/foo", "v2"},
+		{"github.com/a/foo/v10", "github.com/a/foo", "v10"},
+		{"github.com/a/foo/v2/cmd/foo", "github.com/a/foo/v2/cmd/foo", ""},
+		{"github.com/a/foo/v1", "github.com/a/foo/v1", ""},
+		{"github.com/a/foo/v0", "github.com/a/foo/v0", ""},
+		{"github.com/a/foo/v02", "github.com/a/foo/v02", ""},
+		{"github.com/a/foo/v2beta", "github.com/a/foo/v2beta", ""},
+		{"v2", "v2", ""},
+	}
+	for _, tt := range tests {
+		prefix, major := splitMajorVersion(tt.importPath)
+		if prefix != tt.prefix || major != tt.major {
+			t.Errorf("splitMajorVersion(%q) = %q, %q, want %q, %q", tt.importPath, prefix, major, tt.prefix, tt.major)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package pkgbuild
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// lddDepends builds package main in pkgDirs in the repository cloned into dir
+// with cgo, and returns the Arch packages owning the shared libraries linked by
+// the binaries, found by ldd and "pacman -Qo".
+func lddDepends(ctx context.Context, dir string, pkgDirs map[string]pkgDir, buildFlags []string) ([]string, error) {
+	for _, c := range []string{"ldd", "pacman"} {
+		if _, err := exec.LookPath(c); err != nil {
+			return nil, err
+		}
+	}
+
+	binDir, err := ioutil.TempDir("", "genpkgbuild")
+	if err != nil {
+		return nil, fmt.Errorf("could not secure a temp dir: %w", err)
+	}
+	defer os.RemoveAll(binDir)
+
+	var paths []string
+	for p, d := range pkgDirs {
+		if d.exists && d.mainPkg {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var libs []string
+	for i, p := range paths {
+		d := pkgDirs[p]
+		modDir, pkg := p, "."
+		if d.hasModule {
+			modDir = d.moduleDir
+			if rel := strings.TrimPrefix(strings.TrimPrefix(p, modDir), "/"); rel != "" {
+				pkg = "./" + rel
+			}
+		}
+
+		bin := filepath.Join(binDir, fmt.Sprint(i))
+		cmdArgs := append(append([]string{"build", "-o", bin}, buildFlags...), pkg)
+		cmd := exec.CommandContext(ctx, "go", cmdArgs...)
+		cmd.Dir = filepath.Join(dir, filepath.FromSlash(modDir))
+		logger.debugf("running in %s: go %s", cmd.Dir, strings.Join(cmdArgs, " "))
+		cmd.Env = append(os.Environ(), "CGO_ENABLED=1", "GO111MODULE=on")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			logger.debugf("%s", out)
+			return nil, fmt.Errorf("could not build %s: %w", p, err)
+		}
+
+		logger.debugf("running: ldd %s", bin)
+		out, err := exec.CommandContext(ctx, "ldd", bin).Output()
+		if err != nil {
+			// ldd fails for the statically linked binaries.
+			logger.debugf("ldd failed: %v", err)
+			continue
+		}
+		for _, l := range parseLdd(out) {
+			libs = appendUnique(libs, l)
+		}
+	}
+
+	var depends []string
+	for _, l := range libs {
+		logger.debugf("running: pacman -Qoq %s", l)
+		out, err := exec.CommandContext(ctx, "pacman", "-Qoq", l).Output()
+		if err != nil {
+			logger.infof("warning: no package owns %s", l)
+			continue
+		}
+		for _, pkg := range strings.Fields(string(out)) {
+			depends = appendUnique(depends, pkg)
+		}
+	}
+	return depends, nil
+}
+
+// parseLdd returns the paths of the shared libraries in the output of ldd, like
+// "libc.so.6 => /usr/lib/libc.so.6 (0x00007f...)".
+func parseLdd(out []byte) []string {
+	var libs []string
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) > 1 && f[1] == "=>" {
+			f = f[2:]
+		}
+		if len(f) > 0 && filepath.IsAbs(f[0]) {
+			libs = appendUnique(libs, f[0])
+		}
+	}
+	return libs
+}
@@ -0,0 +1,67 @@
+package pkgbuild
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// licenseFileNames are the upper-cased base names, without the extension, of
+// the files which may contain the license text.
+var licenseFileNames = []string{"LICENSE", "LICENCE", "COPYING"}
+
+// licensePatterns are matched against the normalized license text, i.e.
+// lower-cased and with every run of white spaces replaced with a single space.
+var licensePatterns = []struct {
+	id string
+	re *regexp.Regexp
+}{
+	{"Apache-2.0", regexp.MustCompile(`apache license,? version 2\.0`)},
+	{"GPL-3.0", regexp.MustCompile(`gnu general public license,? version 3`)},
+	{"BSD-3-Clause", regexp.MustCompile(`neither the name of .* nor the names of (its|their) contributors may be used`)},
+	{"MIT", regexp.MustCompile(`permission is hereby granted, free of charge, to any person obtaining a copy`)},
+}
+
+// detectLicense guesses the SPDX identifier of the license from the license
+// file in dir. It returns "custom" if there is a license file but its content
+// is not recognized, or "" if no license file is found.
+func detectLicense(dir string) (string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	found := false
+	for _, f := range files {
+		if f.IsDir() || !isLicenseFileName(f.Name()) {
+			continue
+		}
+		found = true
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return "", err
+		}
+		text := strings.ToLower(strings.Join(strings.Fields(string(b)), " "))
+		for _, p := range licensePatterns {
+			if p.re.MatchString(text) {
+				return p.id, nil
+			}
+		}
+	}
+	if found {
+		return "custom", nil
+	}
+	return "", nil
+}
+
+func isLicenseFileName(name string) bool {
+	name = strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+	for _, n := range licenseFileNames {
+		if name == n {
+			return true
+		}
+	}
+	return false
+}
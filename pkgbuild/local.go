@@ -0,0 +1,161 @@
+package pkgbuild
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/vcs"
+)
+
+// localRepo is the local git repository the packages given by the paths are
+// in.
+type localRepo struct {
+	// dir is the top-level directory of the working tree.
+	dir      string
+	repoRoot *vcs.RepoRoot
+	// remote is the URL of the remote "origin", or "" if it's not configured.
+	remote string
+}
+
+// isLocalPath reports whether the argument is the path of the local directory
+// rather than the import path, like "./cmd/foo" or "/src/foo", as the go
+// command tells them apart.
+func isLocalPath(p string) bool {
+	return p == "." || p == ".." || strings.HasPrefix(p, "./") || strings.HasPrefix(p, "../") || filepath.IsAbs(p)
+}
+
+// resolveLocalPaths replaces the local paths in args by the import paths read
+// from the nearest go.mod. It returns the local repository they are in, or nil
+// if none of args is a local path. The paths must be in the same git working
+// tree, and can't be mixed with the import paths.
+func resolveLocalPaths(ctx context.Context, args []string) ([]string, *localRepo, error) {
+	var local *localRepo
+	importPaths := make([]string, len(args))
+	for i, p := range args {
+		if !isLocalPath(p) {
+			if local != nil {
+				return nil, nil, IncorrectUsageError{fmt.Errorf("the import path can't be mixed with the local directories: %s", p)}
+			}
+			importPaths[i] = p
+			continue
+		}
+		if i > 0 && local == nil {
+			return nil, nil, IncorrectUsageError{fmt.Errorf("the local directory can't be mixed with the import paths: %s", p)}
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		if fi, err := os.Stat(abs); err != nil || !fi.IsDir() {
+			return nil, nil, IncorrectUsageError{fmt.Errorf("not a directory: %s", p)}
+		}
+
+		top, err := gitOutput(ctx, abs, "rev-parse", "--show-toplevel")
+		if err != nil {
+			return nil, nil, fmt.Errorf("the directory is not in a git repository: %s: %w", p, err)
+		}
+		if local != nil && top != local.dir {
+			return nil, nil, IncorrectUsageError{fmt.Errorf("the directories are not in the same repository: %s", p)}
+		}
+		rel, err := filepath.Rel(top, abs)
+		if err != nil {
+			return nil, nil, err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		}
+		modDir, ok, err := findModuleDir(top, rel)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			return nil, nil, fmt.Errorf("go.mod is not found in the directory or its parents in the repository: %s", p)
+		}
+		modPath, err := readModulePath(filepath.Join(top, filepath.FromSlash(modDir)))
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read go.mod: %w", err)
+		}
+		if modPath == "" {
+			return nil, nil, fmt.Errorf("the module path is not declared in go.mod in %s", path.Join(top, modDir))
+		}
+		importPaths[i] = strings.TrimSuffix(modPath+"/"+strings.TrimPrefix(strings.TrimPrefix(rel, modDir), "/"), "/")
+
+		if local == nil {
+			root, err := localRepoRootPath(modPath, modDir)
+			if err != nil {
+				return nil, nil, err
+			}
+			// The remote is optional, e.g. for the repository not pushed
+			// yet, and the default one is derived from the import path.
+			remote, _ := gitOutput(ctx, top, "config", "--get", "remote.origin.url")
+			local = &localRepo{
+				dir:      top,
+				repoRoot: &vcs.RepoRoot{VCS: vcs.ByCmd("git"), Repo: "https://" + root, Root: root},
+				remote:   remote,
+			}
+			logger.debugf("the local repository %s: %s (remote %q)", top, root, remote)
+		}
+	}
+	return importPaths, local, nil
+}
+
+// localRepoRootPath returns the import path of the root of the repository with
+// the module of modPath in the directory modDir, relative to the root.
+func localRepoRootPath(modPath, modDir string) (string, error) {
+	if modDir == "" {
+		// The major version suffix isn't a directory in the repository.
+		root, _ := splitMajorVersion(modPath)
+		return root, nil
+	}
+	if !strings.HasSuffix(modPath, "/"+modDir) {
+		return "", fmt.Errorf("can't tell the import path of the repository root from the module path %s in %s", modPath, modDir)
+	}
+	return strings.TrimSuffix(modPath, "/"+modDir), nil
+}
+
+// scpLikeURLRe matches the scp-like URL of git, like "git@github.com:foo/bar".
+var scpLikeURLRe = regexp.MustCompile(`^([A-Za-z0-9_.-]+@)?([A-Za-z0-9.-]+):([^/].*)$`)
+
+// remoteRepoPath returns the host and the path of the git remote URL, without
+// the scheme, the user and ".git", like "github.com/foo/bar". It returns "" for
+// the local remotes.
+func remoteRepoPath(remote string) string {
+	if m := scpLikeURLRe.FindStringSubmatch(remote); m != nil && !strings.Contains(remote, "://") {
+		return strings.TrimSuffix(m[2]+"/"+m[3], ".git")
+	}
+	i := strings.Index(remote, "://")
+	if i < 0 || strings.HasPrefix(remote, "file://") {
+		return ""
+	}
+	p := remote[i+len("://"):]
+	if j := strings.Index(p, "@"); j >= 0 && j < strings.Index(p+"/", "/") {
+		p = p[j+1:]
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(p, "/"), ".git")
+}
+
+// gitOutput runs git in dir and returns the trimmed output.
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	logger.debugf("running in %s: git %s", dir, strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
@@ -0,0 +1,67 @@
+package pkgbuild
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// logLevel is the verbosity of the logs.
+type logLevel int
+
+const (
+	levelInfo logLevel = iota
+	levelDebug
+)
+
+// leveledLogger writes the logs up to the level. It is safe to use from
+// multiple goroutines.
+type leveledLogger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level logLevel
+}
+
+// logger is the logger of the command. The level is set to levelDebug by
+// -verbose.
+var logger = &leveledLogger{w: os.Stderr, level: levelInfo}
+
+// enabled reports whether the logs of the level are written.
+func (l *leveledLogger) enabled(level logLevel) bool {
+	return level <= l.level
+}
+
+func (l *leveledLogger) logf(level logLevel, format string, args ...interface{}) {
+	if !l.enabled(level) {
+		return
+	}
+	msg := strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+	if msg == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.w, msg)
+}
+
+// infof logs the message always.
+func (l *leveledLogger) infof(format string, args ...interface{}) {
+	l.logf(levelInfo, format, args...)
+}
+
+// debugf logs the message only with -verbose.
+func (l *leveledLogger) debugf(format string, args ...interface{}) {
+	l.logf(levelDebug, format, args...)
+}
+
+// SetVerbose makes the logs of the resolution, like the VCS commands and the
+// fetched URLs, written to STDERR. It has to be called before the generation.
+func SetVerbose(verbose bool) {
+	if verbose {
+		logger.level = levelDebug
+	} else {
+		logger.level = levelInfo
+	}
+}
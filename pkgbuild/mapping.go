@@ -0,0 +1,49 @@
+package pkgbuild
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Mapping maps the module paths to the Arch packages, to suggest the
+// dependencies of the package from the modules required in go.mod. A module
+// path also matches its sub-modules, and the longest one wins. It takes
+// precedence over the built-in mapping.
+type Mapping struct {
+	// Depends maps the module paths to the packages added to depends.
+	Depends map[string]string `json:"depends"`
+	// OptDepends maps the module paths to the optional dependencies added to
+	// optdepends, in the form of "pkg: reason".
+	OptDepends map[string]string `json:"optdepends"`
+}
+
+// LoadMapping reads the Mapping from the JSON file at path. The file is an
+// object with the optional keys "depends" and "optdepends", each of which is
+// an object from the module paths to the strings, like:
+//
+//	{
+//	  "depends": {
+//	    "github.com/mattn/go-sqlite3": "sqlite"
+//	  },
+//	  "optdepends": {
+//	    "github.com/atotto/clipboard": "xclip: for the clipboard on X11"
+//	  }
+//	}
+func LoadMapping(path string) (Mapping, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Mapping{}, err
+	}
+	var m Mapping
+	if err := json.Unmarshal(b, &m); err != nil {
+		return Mapping{}, fmt.Errorf("invalid mapping %s: %w", path, err)
+	}
+	for p, d := range m.OptDepends {
+		if i := strings.Index(d, ":"); i <= 0 || strings.TrimSpace(d[i+1:]) == "" {
+			return Mapping{}, fmt.Errorf("invalid mapping %s: the optional dependency of %s is not in the form of \"pkg: reason\": %s", path, p, d)
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,545 @@
+package pkgbuild
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+)
+
+// Options is the options to generate the PKGBUILD. The values not given are
+// asked to the user, or the defaults are used in batch mode. The zero values of
+// PkgRel, Protocol, GoPkg and ServiceType mean the defaults.
+type Options struct {
+	// Format is the output format, "pkgbuild" or "json". The default is
+	// "pkgbuild".
+	Format string
+	// Quiet suppresses the cosmetic messages like "Please wait...".
+	Quiet   bool
+	PkgName string
+	// PkgBase is the pkgbase of the split package. The default is PkgName.
+	PkgBase      string
+	PkgDesc      string
+	Maintainer   string
+	Contributors []string
+	Depends      []string
+	// OptDepends is the optional dependencies in the form of "pkg: reason".
+	OptDepends []string
+	// Timeout is the time limit of cloning and inspecting the repository. Zero
+	// means no limit.
+	Timeout time.Duration
+	// PkgVerFromGoMod packages the release of the latest module version in the
+	// module proxies, unless Tag is given, which must be a module version.
+	PkgVerFromGoMod bool
+	// Dir is the directory the repository is cloned into, used as _pkgname.
+	// The default is the base name of the repository root.
+	Dir string
+	// Mapping is the additional mapping from the modules to the packages, to
+	// suggest depends and optdepends.
+	Mapping Mapping
+	// LddDepends builds the binaries with cgo and suggests the packages owning
+	// the shared libraries linked by them as the dependencies.
+	LddDepends bool
+	// BinNames is the binary names, in the order of the import paths, or of
+	// the commands chosen in their "cmd" directories.
+	BinNames []string
+	// Modes is the file modes of the binaries like "0755", in the order of
+	// the import paths. A single mode is for all the binaries. The default is
+	// 755.
+	Modes []string
+	// Library packages the source without building any binary.
+	Library bool
+	// ManPages and Completions are the files in the repository to be
+	// installed.
+	ManPages    []string
+	Completions []string
+	// Split generates a split package with a package for each binary.
+	Split    bool
+	Licenses []string
+	Arch     []string
+	// Groups is the pacman groups the package belongs to.
+	Groups []string
+	// Tag is the tag of the release to be packaged instead of the VCS source.
+	Tag string
+	// RepoRoot is the import path of the root of the repository, if it's not
+	// detected correctly.
+	RepoRoot string
+	// Repo is the URL of the repository overriding the detected one.
+	Repo string
+	// SourceZip uses the zip of the module in the module proxy for Tag.
+	SourceZip bool
+	Epoch     int
+	PkgRel    int
+	// NoCache doesn't use the cached clone of the repository.
+	NoCache bool
+	// Proxy reads the version and go.mod from the module proxies.
+	Proxy bool
+	// Protocol is the protocol to fetch the git repository. "https", "git"
+	// or "ssh".
+	Protocol    string
+	GoPkg       string
+	MakeDepends []string
+	Provides    []string
+	Conflicts   []string
+	// Replaces is the packages obsoleted by the package, like the old name of
+	// the renamed one.
+	Replaces []string
+	Backup   []string
+	Install  string
+	// Changelog is the file name of the changelog next to the PKGBUILD.
+	Changelog string
+	// Systemd installs the systemd service running the first binary, with
+	// ServiceType and ServiceDesc.
+	Systemd     bool
+	ServiceType string
+	ServiceDesc string
+	LDFlags     string
+	// Strip adds "-s -w" to LDFlags to omit the symbol table and the debug
+	// information.
+	Strip bool
+	// VersionVar is the variable to be set to pkgver with the linker.
+	VersionVar string
+	// PkgVerCmd is the shell script printing the version, overriding the one
+	// of the VCS.
+	PkgVerCmd string
+	CGO       string
+	// GO111Module is GO111MODULE of the go commands, like "auto". "none" omits
+	// it. The default is "on".
+	GO111Module string
+	// GoToolchain is GOTOOLCHAIN of the go commands, like "local" not to
+	// download the newer toolchain.
+	GoToolchain string
+	BuildFlags  []string
+	TrimPath    bool
+	ModDownload bool
+	Guidelines  bool
+	// Reproducible builds the release reproducibly, with TrimPath and
+	// ModDownload, and without the build ID. It requires the tag.
+	Reproducible bool
+	// Branch is the branch of the git repository to be packaged, instead of
+	// the default one.
+	Branch string
+	// Commit is the hash of the git commit the source is pinned to.
+	Commit string
+	// NoVersion skips cloning the repository to read the version, and PkgVer,
+	// or the placeholder, is used as pkgver. The license, the dependencies and
+	// the nested modules are not detected.
+	NoVersion bool
+	PkgVer    string
+	// NamedSource prefixes the VCS source with "$_pkgname::", so that it's
+	// cloned into the directory regardless of the name of the repository.
+	NamedSource bool
+	// BinDir is the absolute directory the binaries are installed into. The
+	// default is /usr/bin.
+	BinDir string
+	// Submodules checks out the git submodules of the repository in prepare().
+	Submodules bool
+	// Check checks the PKGBUILD with bash and makepkg before writing it.
+	Check bool
+	// Strict fails on the import path which is not a command, instead of
+	// warning.
+	Strict bool
+	// Template is rendered instead of the built-in one if not nil.
+	Template *template.Template
+
+	// localDir is the working tree of the local directory given as the
+	// argument, read instead of cloning the repository.
+	localDir string
+}
+
+// IncorrectUsageError is the error caused by the invalid options, e.g. the
+// malformed values or the ones which conflict with the repository.
+type IncorrectUsageError struct {
+	error
+}
+
+var dquoteReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`")
+
+var squoteReplacer = strings.NewReplacer(`'`, `'\''`)
+
+var shellSafeRe = regexp.MustCompile(`^[A-Za-z0-9_./=:,+@%-]+$`)
+
+// ShellWord quotes the string as a single word of shell scripts, only if it
+// contains any special characters. It's the function "shellword" of the
+// template.
+func ShellWord(s string) string {
+	if shellSafeRe.MatchString(s) {
+		return s
+	}
+	return "'" + squoteReplacer.Replace(s) + "'"
+}
+
+// indent indents the lines of s with n spaces.
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.Replace(s, "\n", "\n"+pad, -1)
+}
+
+var tmplFuncs = template.FuncMap{
+	// squote escapes the string to be put in single quotes in shell scripts.
+	"squote": squoteReplacer.Replace,
+	// dquote escapes the string to be put in double quotes in shell scripts.
+	// "$" is not escaped to allow to refer the variables.
+	"dquote": dquoteReplacer.Replace,
+	// shellword quotes the string as a single word in shell scripts if needed.
+	"shellword": ShellWord,
+	// indent indents the lines of the string with n spaces.
+	"indent": indent,
+}
+
+// DefaultTemplate is the built-in PKGBUILD template, documenting the variables
+// in the comment at the top. It's the base of the custom templates for
+// Options.Template.
+const DefaultTemplate = `{{- /*
+Variables:
+- .Maintainer:   Optional. The maintainer of this package, in the form of "Name <email>".
+- .Contributors: Optional. The contributors of this package, in the same form as .Maintainer.
+- .PkgName:      Required.
+- .PkgDesc:      Optional. The description of this package.
+- .Dir:          Required. The directory name which is the destination of "git clone", or
+                 the one which the tarball or the zip of the module is extracted into.
+- .PkgVer:       Required.
+- .PkgRel:       Required. The release number of this package.
+- .Epoch:        Optional. The epoch of this package. Not rendered if zero.
+- .Tag:          Optional. The tag of the release. If set, the tarball of it is used as the source.
+- .VCS:          Required. The VCS command name. "git", "hg", "svn" or "bzr".
+- .PkgVerCmd:    Required. The script in pkgver(), printing the version of the VCS source.
+- .Arch:         Required. The architectures. e.g. x86_64, aarch64, any
+- .Repo:         Required. Repository URL.
+- .License:      Required. The licenses of this package.
+- .Groups:       Optional. The pacman groups this package belongs to.
+- .Root:         Required. The import path corresponding to the root of the repository.
+- .Protocol:     Required. The protocol to fetch the git repository. "https", "git" or "ssh".
+- .Source:       Required. The VCS source of the repository, or the tarball or the module zip
+                 of the release.
+- .NamedSource:  Optional. Whether the VCS source is prefixed with "$_pkgname::", to be cloned
+                 into .Dir.
+- .Backup:       Optional. The config files to be kept on upgrades, relative to "/".
+- .Install:      Optional. The file name of the install script next to the PKGBUILD.
+- .Changelog:    Optional. The file name of the changelog next to the PKGBUILD.
+- .UpstreamChangelog:
+                 Optional. The content of the changelog in the repository, like CHANGELOG.md.
+- .Service:      Optional. The file name of the systemd service next to the PKGBUILD, like
+                 "foo.service". If set, it's the second source.
+- .Sha256Sums:   Required. The checksums of the source. "SKIP" for the VCS source.
+- .Depends:      Optional. The dependencies of this package.
+- .OptDepends:   Optional. The optional dependencies of this package, in the form of
+                 "pkg: reason".
+- .ModRequires:  Optional. The modules required in go.mod which are not mapped to any package.
+- .GoPkg:        Required. The package providing the Go toolchain. e.g. go, gcc-go
+- .MakeDepends:  Optional. The build-time dependencies of this package other than .GoPkg,
+                 sorted. It includes the VCS like git for the VCS source.
+- .Provides:     Optional. The virtual packages this package provides.
+- .Conflicts:    Optional. The packages conflicting with this package.
+- .Replaces:     Optional. The packages replaced by this package, like the old name of it.
+- .LDFlags:      Optional. The flags passed to "go build -ldflags".
+- .CGO:          Optional. "on" or "off" to set CGO_ENABLED explicitly.
+- .GO111Module:  Optional. GO111MODULE of the go commands. e.g. on
+- .GoToolchain:  Optional. GOTOOLCHAIN of the go commands. e.g. local
+- .BuildFlags:   Optional. The additional flags passed to "go build", one for each element.
+- .Library:      Optional. Whether the package is a library without commands. If true,
+                 nothing is built and the source is installed into /usr/share/gocode.
+- .ModDownload:  Optional. Whether the modules are downloaded in prepare() into
+                 "$srcdir/gopath", and built with -mod=readonly.
+- .Submodules:   Optional. Whether prepare() checks out the git submodules of the repository.
+- .Guidelines:   Optional. Whether build() exports the environment variables recommended by
+                 the Go package guidelines of Arch Linux, like GOFLAGS and CGO_CFLAGS.
+- .ModDirs:      Required. The distinct .ModDir of .Binaries.
+- .Binaries:     Required. The binaries to be installed. Each of them has:
+  - .Name:       Required. The final binary name.
+  - .Path:       Optional. The relative import path from the root of the repository.
+  - .ModDir:     Optional. The relative path of the module containing the package from the
+                 root of the repository, where "go build" runs.
+  - .Pkg:        Required. The package to be built, relative to .ModDir. e.g. ".", "./cmd/foo"
+  - .Mode:       Required. The file mode the binary is installed with. e.g. 755
+- .BinDir:       Required. The absolute directory the binaries are installed into. e.g. /usr/bin
+- .ManPages:     Optional. The man pages to be installed. Each of them has:
+  - .Src:        Required. The relative path from the root of the repository.
+  - .Dest:       Required. The absolute path to be installed into.
+- .Completions:  Optional. The shell completion files to be installed, in the same form as
+                 .ManPages.
+- .PkgBase:      Optional. The pkgbase of the split package, with -split. The default is
+                 .PkgName.
+- .SplitPkgs:    Optional. The packages split from .PkgBase, with -split. Each of them has:
+  - .Name:       Required. The package name.
+  - .Provides:   Optional. The virtual packages the package provides.
+  - .Conflicts:  Optional. The packages conflicting with the package.
+  - .Replaces:   Optional. The packages replaced by the package.
+  - .Binaries:   Optional. The binaries to be installed by the package, in the same form as
+                 the ones in .Binaries.
+  - .BinDir:     Required. The same as .BinDir.
+  - .ManPages:   Optional. The man pages to be installed by the package.
+  - .Completions: Optional. The shell completion files to be installed by the package.
+  - .Service:    Optional. The systemd service to be installed by the package.
+
+Functions:
+- squote:        Escapes the string to be put in single quotes.
+- dquote:        Escapes the string to be put in double quotes, leaving "$" as is.
+- shellword:     Quotes the string as a single word, only if it contains special characters.
+- indent:        Indents the lines of the string with the spaces. e.g. {{indent 2 .PkgVerCmd}}
+
+This template can be replaced with -template flag. The template is parsed as a Go text/template
+with the same variables and functions.
+*/ -}}
+{{- if .Maintainer}}# Maintainer: {{.Maintainer}}
+{{end}}
+{{- range .Contributors}}# Contributor: {{.}}
+{{end -}}
+{{if .SplitPkgs -}}
+pkgbase={{.PkgBase}}
+pkgname=({{range $i, $v := .SplitPkgs}}{{if $i}} {{end}}'{{.Name}}'{{end}})
+{{- else -}}
+pkgname={{.PkgName}}
+{{- end}}
+_pkgname={{.Dir}}
+pkgver={{.PkgVer}}
+pkgrel={{.PkgRel}}
+{{- if .Epoch}}
+epoch={{.Epoch}}
+{{- end}}
+pkgdesc='{{squote .PkgDesc}}'
+arch=({{range $i, $v := .Arch}}{{if $i}} {{end}}'{{.}}'{{end}})
+url='{{.Repo}}'
+license=({{range $i, $v := .License}}{{if $i}} {{end}}'{{.}}'{{end}})
+{{- if .Groups}}
+groups=({{range $i, $v := .Groups}}{{if $i}} {{end}}'{{.}}'{{end}})
+{{- end}}
+source=({{if .NamedSource}}"$_pkgname::{{dquote .Source}}"{{else}}'{{.Source}}'{{end}}{{if .Service}} '{{.Service}}'{{end}})
+{{- if .ModRequires}}
+# The modules required in go.mod with no known package. Review them:
+{{- range .ModRequires}}
+#   {{.}}
+{{- end}}
+{{- end}}
+depends=({{range $i, $v := .Depends}}{{if $i}} {{end}}'{{.}}'{{end}})
+{{- if .OptDepends}}
+optdepends=({{range $i, $v := .OptDepends}}{{if $i}} {{end}}'{{squote .}}'{{end}})
+{{- end}}
+makedepends=('{{.GoPkg}}'{{range .MakeDepends}} '{{.}}'{{end}})
+{{- if .Provides}}
+provides=({{range $i, $v := .Provides}}{{if $i}} {{end}}'{{.}}'{{end}})
+{{- end}}
+{{- if .Conflicts}}
+conflicts=({{range $i, $v := .Conflicts}}{{if $i}} {{end}}'{{.}}'{{end}})
+{{- end}}
+{{- if .Replaces}}
+replaces=({{range $i, $v := .Replaces}}{{if $i}} {{end}}'{{.}}'{{end}})
+{{- end}}
+{{- if .Backup}}
+backup=({{range $i, $v := .Backup}}{{if $i}} {{end}}'{{squote .}}'{{end}})
+{{- end}}
+{{- if .Install}}
+install='{{squote .Install}}'
+{{- end}}
+{{- if .Changelog}}
+changelog='{{squote .Changelog}}'
+{{- end}}
+sha256sums=({{range $i, $v := .Sha256Sums}}{{if $i}} {{end}}'{{.}}'{{end}})
+{{- if or .Submodules .ModDownload}}
+
+prepare() {
+{{- if .Submodules}}
+  cd "$srcdir/$_pkgname"
+  git submodule update --init --recursive
+{{- end}}
+{{- if .ModDownload}}
+  export GOPATH="$srcdir/gopath"
+{{- range .ModDirs}}
+  cd "$srcdir/$_pkgname{{if .}}/{{.}}{{end}}"
+  {{template "goenv" $}}go mod download -modcacherw
+{{- end}}
+{{- end}}
+}
+{{- end}}
+{{- if not .Tag}}
+
+pkgver() {
+  cd "$srcdir/$_pkgname"
+{{indent 2 .PkgVerCmd}}
+}
+{{- end}}
+{{- if not .Library}}
+
+build(){
+{{- if .Guidelines}}
+  export GOPATH="$srcdir/gopath"
+  export GOCACHE="$srcdir/gocache"
+  export CGO_CPPFLAGS="${CPPFLAGS}"
+  export CGO_CFLAGS="${CFLAGS}"
+  export CGO_CXXFLAGS="${CXXFLAGS}"
+  export CGO_LDFLAGS="${LDFLAGS}"
+  export GOFLAGS="-buildmode=pie -trimpath -ldflags=-linkmode=external -mod=readonly -modcacherw"
+{{- else if .ModDownload}}
+  export GOPATH="$srcdir/gopath"
+{{- end}}
+{{- range .Binaries}}
+  cd "$srcdir/$_pkgname{{if .ModDir}}/{{.ModDir}}{{end}}"
+  {{if eq $.CGO "on"}}CGO_ENABLED=1 {{else if eq $.CGO "off"}}CGO_ENABLED=0 {{end}}{{template "goenv" $}}go build{{if $.ModDownload}} -mod=readonly{{end}}{{range $.BuildFlags}} {{shellword .}}{{end}}{{if $.LDFlags}} -ldflags "{{if $.Guidelines}}-linkmode=external {{end}}{{dquote $.LDFlags}}"{{end}} -o "$srcdir/bin/{{.Name}}" {{shellword .Pkg}}
+{{- end}}
+}
+{{- end}}
+
+{{- if .SplitPkgs}}
+{{- range .SplitPkgs}}
+
+package_{{.Name}}() {
+{{- if .Provides}}
+  provides=({{range $i, $v := .Provides}}{{if $i}} {{end}}'{{.}}'{{end}})
+{{- end}}
+{{- if .Conflicts}}
+  conflicts=({{range $i, $v := .Conflicts}}{{if $i}} {{end}}'{{.}}'{{end}})
+{{- end}}
+{{- if .Replaces}}
+  replaces=({{range $i, $v := .Replaces}}{{if $i}} {{end}}'{{.}}'{{end}})
+{{- end}}
+{{- template "install" .}}
+}
+{{- end}}
+{{- else}}
+
+package() {
+{{- if .Library}}
+  cd "$srcdir/$_pkgname"
+  find . -path './.*' -prune -o -type f -exec install -Dm644 {} "$pkgdir/usr/share/gocode/src/{{.Root}}/{}" \;
+{{- end}}
+{{- template "install" .}}
+}
+{{- end}}
+{{- define "goenv"}}
+{{- if .GoToolchain}}GOTOOLCHAIN={{shellword .GoToolchain}} {{end}}
+{{- if .GO111Module}}GO111MODULE={{shellword .GO111Module}} {{end}}
+{{- end}}
+{{- define "install"}}
+{{- if .Binaries}}
+  cd "$srcdir/bin"
+{{- end}}
+{{- range .Binaries}}
+  install -Dm{{.Mode}} '{{.Name}}' "$pkgdir{{dquote $.BinDir}}/{{.Name}}"
+{{- end}}
+{{- range .ManPages}}
+  install -Dm644 "$srcdir/$_pkgname/{{dquote .Src}}" "$pkgdir{{dquote .Dest}}"
+{{- end}}
+{{- range .Completions}}
+  install -Dm644 "$srcdir/$_pkgname/{{dquote .Src}}" "$pkgdir{{dquote .Dest}}"
+{{- end}}
+{{- if .Service}}
+  install -Dm644 "$srcdir/{{dquote .Service}}" "$pkgdir/usr/lib/systemd/system/{{dquote .Service}}"
+{{- end}}
+{{- end}}
+`
+
+var tmpl = template.Must(template.New("PKGBUILD").Funcs(tmplFuncs).Parse(DefaultTemplate))
+
+// ParseTemplateFile parses the PKGBUILD template in the file at path, with the
+// functions available in the default one, for Options.Template.
+func ParseTemplateFile(path string) (*template.Template, error) {
+	return template.New(filepath.Base(path)).Funcs(tmplFuncs).ParseFiles(path)
+}
+
+// TmplData is the values rendered into the PKGBUILD.
+type TmplData struct {
+	PkgName      string   `json:"pkgName"`
+	PkgBase      string   `json:"pkgBase"`
+	Maintainer   string   `json:"maintainer"`
+	Contributors []string `json:"contributors"`
+	PkgDesc      string   `json:"pkgDesc"`
+	Dir          string   `json:"dir"`
+	PkgVer       string   `json:"pkgVer"`
+	PkgRel       int      `json:"pkgRel"`
+	Epoch        int      `json:"epoch"`
+	Tag          string   `json:"tag"`
+	VCS          string   `json:"vcs"`
+	PkgVerCmd    string   `json:"pkgVerCmd"`
+	Arch         []string `json:"arch"`
+	Repo         string   `json:"repo"`
+	License      []string `json:"license"`
+	Groups       []string `json:"groups"`
+	Root         string   `json:"root"`
+	Protocol     string   `json:"protocol"`
+	Source       string   `json:"source"`
+	NamedSource  bool     `json:"namedSource"`
+	Service      string   `json:"service"`
+	Sha256Sums   []string `json:"sha256Sums"`
+	Depends      []string `json:"depends"`
+	OptDepends   []string `json:"optDepends"`
+	ModRequires  []string `json:"modRequires"`
+	GoPkg        string   `json:"goPkg"`
+	MakeDepends  []string `json:"makeDepends"`
+	Provides     []string `json:"provides"`
+	Conflicts    []string `json:"conflicts"`
+	Replaces     []string `json:"replaces"`
+	Backup       []string `json:"backup"`
+	Install      string   `json:"install"`
+	Changelog    string   `json:"changelog"`
+	// UpstreamChangelog is not in JSON, nor printed by PrintData, not to
+	// flood the output.
+	UpstreamChangelog string        `json:"-"`
+	LDFlags           string        `json:"ldflags"`
+	CGO               string        `json:"cgo"`
+	GO111Module       string        `json:"go111module"`
+	GoToolchain       string        `json:"goToolchain"`
+	BuildFlags        []string      `json:"buildFlags"`
+	Library           bool          `json:"library"`
+	ModDownload       bool          `json:"modDownload"`
+	Submodules        bool          `json:"submodules"`
+	Guidelines        bool          `json:"guidelines"`
+	ModDirs           []string      `json:"modDirs"`
+	Binaries          []Binary      `json:"binaries"`
+	BinDir            string        `json:"binDir"`
+	ManPages          []InstallFile `json:"manPages"`
+	Completions       []InstallFile `json:"completions"`
+	SplitPkgs         []SplitPkg    `json:"splitPkgs"`
+}
+
+// SplitPkg is a package split from pkgbase.
+type SplitPkg struct {
+	Name        string        `json:"name"`
+	Provides    []string      `json:"provides"`
+	Conflicts   []string      `json:"conflicts"`
+	Replaces    []string      `json:"replaces"`
+	Binaries    []Binary      `json:"binaries"`
+	BinDir      string        `json:"binDir"`
+	ManPages    []InstallFile `json:"manPages"`
+	Completions []InstallFile `json:"completions"`
+	Service     string        `json:"service"`
+}
+
+type Binary struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	ModDir string `json:"modDir"`
+	Pkg    string `json:"pkg"`
+	Mode   string `json:"mode"`
+}
+
+// writeJSON writes data as JSON.
+func writeJSON(w io.Writer, data TmplData) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(data)
+}
+
+// PrintData prints the fields of data as key/value lines.
+func PrintData(w io.Writer, data TmplData) {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	v := reflect.ValueOf(data)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("json") == "-" {
+			continue
+		}
+		format := "%s:\t%+v\n"
+		switch v.Field(i).Interface().(type) {
+		case string, []string:
+			format = "%s:\t%q\n"
+		}
+		fmt.Fprintf(tw, format, v.Type().Field(i).Name, v.Field(i).Interface())
+	}
+	tw.Flush()
+}
@@ -0,0 +1,38 @@
+package pkgbuild
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTemplateCGO(t *testing.T) {
+	tests := []struct {
+		cgo  string
+		want string
+	}{
+		{"", `  GO111MODULE=on go build -o "$srcdir/bin/foo" ./cmd/foo`},
+		{"on", `  CGO_ENABLED=1 GO111MODULE=on go build -o "$srcdir/bin/foo" ./cmd/foo`},
+		{"off", `  CGO_ENABLED=0 GO111MODULE=on go build -o "$srcdir/bin/foo" ./cmd/foo`},
+	}
+	for _, tt := range tests {
+		data := TmplData{
+			PkgName:     "foo-git",
+			Dir:         "foo",
+			PkgVer:      "r1.abc1234",
+			PkgRel:      1,
+			VCS:         "git",
+			GoPkg:       "go",
+			GO111Module: "on",
+			CGO:         tt.cgo,
+			Binaries:    []Binary{{Name: "foo", Path: "cmd/foo", Pkg: "./cmd/foo", Mode: "755"}},
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "\n"+tt.want+"\n") {
+			t.Errorf("CGO %q: build() doesn't contain %q:\n%s", tt.cgo, tt.want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package pkgbuild
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var invalidPkgNameCharRe = regexp.MustCompile(`[^a-z0-9@._+-]+`)
+
+// checkPkgName returns an error if the package name is not allowed by pacman.
+// It must consist of lowercase alphanumerics and "@._+-", and must not start
+// with a hyphen or a dot.
+func checkPkgName(name string) error {
+	if name == "" {
+		return errors.New("the package name is empty")
+	}
+	if invalidPkgNameCharRe.MatchString(name) {
+		return fmt.Errorf("the package name must consist of lowercase alphanumerics and \"@._+-\": %s", name)
+	}
+	if strings.HasPrefix(name, "-") || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("the package name must not start with a hyphen or a dot: %s", name)
+	}
+	return nil
+}
+
+// sanitizePkgName converts the name into the one allowed by pacman, by
+// lowercasing it and replacing the invalid characters with hyphens. It returns
+// "" if nothing is left.
+func sanitizePkgName(name string) string {
+	name = invalidPkgNameCharRe.ReplaceAllString(strings.ToLower(name), "-")
+	return strings.TrimLeft(name, "-.")
+}
+
+// dependRe matches the dependency in the syntax of pacman, like "foo",
+// "foo>=1.0" or "foo=1:1.0-1".
+var dependRe = regexp.MustCompile(`^[a-z0-9@_+][a-z0-9@._+-]*(([<>]=?|=)([0-9]+:)?[A-Za-z0-9._+~]+(-[0-9.]+)?)?$`)
+
+// checkDepends returns an error if any of the dependencies is malformed.
+func checkDepends(depends []string) error {
+	for _, d := range depends {
+		if !dependRe.MatchString(d) {
+			return fmt.Errorf("the dependency must be a package name optionally with the version constraint like \"foo>=1.0\": %s", d)
+		}
+	}
+	return nil
+}
+
+// invalidPkgNameError returns the error of the invalid package name given by
+// the flag, suggesting the sanitized one if any.
+func invalidPkgNameError(flag, name string, err error) error {
+	if s := sanitizePkgName(name); s != "" {
+		return IncorrectUsageError{fmt.Errorf("invalid %s: %w. e.g. %s", flag, err, s)}
+	}
+	return IncorrectUsageError{fmt.Errorf("invalid %s: %w", flag, err)}
+}
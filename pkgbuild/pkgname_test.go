@@ -0,0 +1,55 @@
+package pkgbuild
+
+import "testing"
+
+func TestCheckPkgName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"foo", true},
+		{"foo-git", true},
+		{"foo_bar.baz+1@2", true},
+		{"", false},
+		{"Foo", false},
+		{"foo bar", false},
+		{"-foo", false},
+		{".foo", false},
+		{"foo/bar", false},
+	}
+	for _, tt := range tests {
+		err := checkPkgName(tt.name)
+		if tt.valid && err != nil {
+			t.Errorf("checkPkgName(%q): %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("checkPkgName(%q) succeeded, want an error", tt.name)
+		}
+	}
+}
+
+func TestSanitizePkgName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"foo", "foo"},
+		{"FooBar", "foobar"},
+		{"foo bar", "foo-bar"},
+		{"foo  /bar", "foo-bar"},
+		{"-foo", "foo"},
+		{".-foo", "foo"},
+		{"---", ""},
+	}
+	for _, tt := range tests {
+		got := sanitizePkgName(tt.name)
+		if got != tt.want {
+			t.Errorf("sanitizePkgName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		if got != "" {
+			if err := checkPkgName(got); err != nil {
+				t.Errorf("sanitizePkgName(%q) = %q is invalid: %v", tt.name, got, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package pkgbuild
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/go/vcs"
+)
+
+// defaultGoProxy is used if GOPROXY is not set.
+const defaultGoProxy = "https://proxy.golang.org"
+
+// goProxies returns the module proxies listed in GOPROXY for the module path.
+// It returns nothing if the module is private by GOPRIVATE or GONOPROXY.
+func goProxies(modPath string) []string {
+	noProxy := os.Getenv("GONOPROXY")
+	if noProxy == "" {
+		noProxy = os.Getenv("GOPRIVATE")
+	}
+	if matchPathPatterns(noProxy, modPath) {
+		return nil
+	}
+
+	env := os.Getenv("GOPROXY")
+	if env == "" {
+		env = defaultGoProxy
+	}
+	var proxies []string
+	for _, p := range strings.FieldsFunc(env, func(r rune) bool { return r == ',' || r == '|' }) {
+		switch p = strings.TrimSpace(p); p {
+		case "off":
+			return proxies
+		case "direct", "":
+		default:
+			proxies = append(proxies, strings.TrimSuffix(p, "/"))
+		}
+	}
+	return proxies
+}
+
+// matchPathPatterns reports whether the module path or its prefix matches any
+// of the comma-separated glob patterns, like GOPRIVATE.
+func matchPathPatterns(patterns, modPath string) bool {
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSuffix(strings.TrimSpace(pattern), "/")
+		if pattern == "" {
+			continue
+		}
+		elems := strings.Split(modPath, "/")
+		n := strings.Count(pattern, "/") + 1
+		if n > len(elems) {
+			continue
+		}
+		if ok, _ := path.Match(pattern, strings.Join(elems[:n], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inspectProxy reads the information of the module at the root of the
+// repository from the module proxies, without cloning it. The license and the
+// directories of the packages are not available from the proxies.
+func inspectProxy(ctx context.Context, repoRoot *vcs.RepoRoot, opts Options) (repoInfo, error) {
+	modPath := repoRoot.Root
+	proxies := goProxies(modPath)
+	if len(proxies) == 0 {
+		return repoInfo{}, errors.New("no module proxy is available")
+	}
+	escaped, err := module.EscapePath(modPath)
+	if err != nil {
+		return repoInfo{}, err
+	}
+
+	var lastErr error
+	for _, proxy := range proxies {
+		base := fmt.Sprintf("%s/%s/@v/", proxy, escaped)
+
+		version := opts.Tag
+		if version == "" {
+			version, err = proxyVersion(ctx, proxy, escaped)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+		}
+		escapedVersion, err := module.EscapeVersion(version)
+		if err != nil {
+			return repoInfo{}, err
+		}
+		gomod, err := proxyGet(ctx, base+escapedVersion+".mod")
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ds, err := parseGoModDepends("go.mod", gomod, opts.Mapping)
+		if err != nil {
+			return repoInfo{}, fmt.Errorf("could not read go.mod: %w", err)
+		}
+
+		return repoInfo{
+			version:     tagVersion(version),
+			depends:     ds.depends,
+			optDepends:  ds.optDepends,
+			modRequires: ds.unknown,
+			pkgDirs:     make(map[string]pkgDir),
+		}, nil
+	}
+	return repoInfo{}, lastErr
+}
+
+// proxyZipSource returns the URL of the zip of the module version in the first
+// module proxy, and the name of the directory the zip is extracted into.
+func proxyZipSource(modPath, version string) (url string, dir string, err error) {
+	if !semver.IsValid(version) {
+		return "", "", fmt.Errorf("the tag is not a valid module version: %s", version)
+	}
+	proxies := goProxies(modPath)
+	if len(proxies) == 0 {
+		return "", "", errors.New("no module proxy is available")
+	}
+	escaped, err := module.EscapePath(modPath)
+	if err != nil {
+		return "", "", err
+	}
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%s/%s/@v/%s.zip", proxies[0], escaped, escapedVersion), modPath + "@" + version, nil
+}
+
+// proxyVersion returns the latest release version of the module in the proxy,
+// or the pseudo-version of the latest commit if there is no release.
+func proxyVersion(ctx context.Context, proxy, escapedPath string) (string, error) {
+	list, err := proxyGet(ctx, fmt.Sprintf("%s/%s/@v/list", proxy, escapedPath))
+	if err != nil {
+		return "", err
+	}
+	var latest string
+	for _, v := range strings.Fields(string(list)) {
+		if semver.IsValid(v) && semver.Prerelease(v) == "" && semver.Compare(v, latest) > 0 {
+			latest = v
+		}
+	}
+	if latest != "" {
+		return latest, nil
+	}
+
+	b, err := proxyGet(ctx, fmt.Sprintf("%s/%s/@latest", proxy, escapedPath))
+	if err != nil {
+		return "", err
+	}
+	var info struct {
+		Version string
+	}
+	if err := json.Unmarshal(b, &info); err != nil {
+		return "", fmt.Errorf("could not decode the version info: %w", err)
+	}
+	if info.Version == "" {
+		return "", errors.New("no version is found in the module proxy")
+	}
+	return info.Version, nil
+}
+
+// pseudoVersionRe matches the suffix of the pseudo-versions like
+// "v0.0.0-20191109021931-daa7c04131f5".
+var pseudoVersionRe = regexp.MustCompile(`[-.][0-9]{14}-[0-9a-f]{12}$`)
+
+// latestModuleVersion returns the latest release version of the module in the
+// module proxies, which is also the tag of the release. It fails if the module
+// has no release.
+func latestModuleVersion(ctx context.Context, modPath string) (string, error) {
+	proxies := goProxies(modPath)
+	if len(proxies) == 0 {
+		return "", errors.New("no module proxy is available")
+	}
+	escaped, err := module.EscapePath(modPath)
+	if err != nil {
+		return "", err
+	}
+
+	var lastErr error
+	for _, proxy := range proxies {
+		v, err := proxyVersion(ctx, proxy, escaped)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		v = strings.TrimSuffix(v, "+incompatible")
+		if pseudoVersionRe.MatchString(v) {
+			return "", fmt.Errorf("%s has no release, only the pseudo-version %s", modPath, v)
+		}
+		return v, nil
+	}
+	return "", lastErr
+}
+
+func proxyGet(ctx context.Context, url string) ([]byte, error) {
+	logger.debugf("GET %s", url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,43 @@
+package pkgbuild
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// tarballSource returns the URL of the archive of the tag in the repository
+// root, and the name of the directory the archive is extracted into.
+func tarballSource(root, tag string) (url string, dir string, err error) {
+	name := path.Base(root)
+	switch {
+	case strings.HasPrefix(root, "github.com/"):
+		// GitHub drops the leading "v" of the tag from the directory name.
+		v := tag
+		if len(v) > 1 && v[0] == 'v' && '0' <= v[1] && v[1] <= '9' {
+			v = v[1:]
+		}
+		return fmt.Sprintf("https://%s/archive/%s.tar.gz", root, tag), fmt.Sprintf("%s-%s", name, v), nil
+	case strings.HasPrefix(root, "gitlab.com/"):
+		return fmt.Sprintf("https://%s/-/archive/%s/%s-%s.tar.gz", root, tag, name, tag), fmt.Sprintf("%s-%s", name, tag), nil
+	}
+	return "", "", fmt.Errorf("sorry, the tarball of the release is not supported for the host: %s", root)
+}
+
+var (
+	tagPrefixRe     = regexp.MustCompile(`^[^0-9]*`)
+	preReleaseSepRe = regexp.MustCompile(`-([[:alpha:]])`)
+	invalidVerRe    = regexp.MustCompile(`[-:/]`)
+)
+
+// tagVersion converts the tag name to pkgver, in the same way as pkgver() of
+// git. The prefix before the first digit, like "v" or "release/", is removed,
+// and the hyphen before the pre-release suffix is removed to be sorted before
+// the release by pacman, like "1.2.3rc1". The other characters not allowed in
+// pkgver are replaced with ".".
+func tagVersion(tag string) string {
+	v := tagPrefixRe.ReplaceAllString(tag, "")
+	v = preReleaseSepRe.ReplaceAllString(v, "$1")
+	return invalidVerRe.ReplaceAllString(v, ".")
+}
@@ -0,0 +1,41 @@
+package pkgbuild
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTagVersion(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"v1.2.3", "1.2.3"},
+		{"1.2.3", "1.2.3"},
+		{"release-1.2", "1.2"},
+		{"release/1.2", "1.2"},
+		{"foo/v1.2.3", "1.2.3"},
+		{"v1.2.3-rc1", "1.2.3rc1"},
+		{"v1.2.3-beta.2", "1.2.3beta.2"},
+		{"v2.0.0-rc.1+build.5", "2.0.0rc.1+build.5"},
+		{"v1.2.3-1", "1.2.3.1"},
+		{"go1.21.0", "1.21.0"},
+		{"2020-01-02", "2020.01.02"},
+	}
+	for _, tt := range tests {
+		if got := tagVersion(tt.tag); got != tt.want {
+			t.Errorf("tagVersion(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+
+		// pkgver() of git converts the tag in the same way, followed by the
+		// commits since the tag.
+		g := &Generator{runner: &fakeRunner{describe: tt.tag + "-5-gabc1234"}}
+		got, err := g.getVersion(context.Background(), ".", gitBackend{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tt.want + ".r5.gabc1234"; got != want {
+			t.Errorf("pkgver() for %q = %q, want %q", tt.tag, got, want)
+		}
+	}
+}
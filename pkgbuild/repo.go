@@ -0,0 +1,308 @@
+package pkgbuild
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/vcs"
+)
+
+// repoInfo is the information read from the cloned repository.
+type repoInfo struct {
+	version     string
+	license     string
+	depends     []string
+	optDepends  []string
+	modRequires []string
+	// submodules is whether the repository has .gitmodules.
+	submodules  bool
+	sha256sums  []string
+	description string
+	// changelog is the content of the changelog at the root of the
+	// repository.
+	changelog string
+	pkgDirs   map[string]pkgDir
+}
+
+// pkgDir is the information of the directory of a package to be built.
+type pkgDir struct {
+	exists bool
+	// moduleDir is the directory of the nearest go.mod, relative to the root
+	// of the repository. It is valid only if hasModule is true.
+	moduleDir string
+	hasModule bool
+	// modulePath is the module path declared in the nearest go.mod.
+	modulePath string
+	// mainPkg is whether the directory contains package main.
+	mainPkg bool
+	// cmdDirs is the directories of package main under "cmd" in the directory,
+	// relative to the root of the repository, if it is not package main.
+	cmdDirs []string
+}
+
+// inspectRepo clones the repository and reads the information from it,
+// including the directories of pkgPaths, relative to the root of the
+// repository. If the tag is given by -tag, the tag is checked out and the
+// version is derived from it. The working tree of the local directory is read
+// as it is instead, without cloning or checking out anything.
+func (g *Generator) inspectRepo(ctx context.Context, repoRoot *vcs.RepoRoot, pkgPaths []string) (repoInfo, error) {
+	opts := g.opts
+	// The VCS is checked to be supported by resolveData.
+	backend := vcsBackends[repoRoot.VCS.Cmd]
+	if opts.NoVersion || opts.PkgVer != "" {
+		// Nothing is read from the repository, e.g. for the stub.
+		version := opts.PkgVer
+		if version == "" {
+			version = "0"
+			if opts.Tag != "" {
+				version = tagVersion(opts.Tag)
+			}
+		}
+		return repoInfo{version: version, pkgDirs: make(map[string]pkgDir)}, nil
+	}
+	if opts.localDir != "" {
+		return g.readRepo(ctx, opts.localDir, repoRoot, pkgPaths)
+	}
+	if opts.Proxy {
+		// Clone the repository if the proxies are not available.
+		info, err := inspectProxy(ctx, repoRoot, opts)
+		if err == nil {
+			return info, nil
+		}
+		logger.debugf("falling back to cloning: %v", err)
+	}
+
+	// With -protocol ssh, the repository is cloned with ssh, too, so that the
+	// private one can be authenticated by the SSH agent and ~/.ssh/config.
+	// The mirror given by -repo is used as is.
+	if repoRoot.VCS.Cmd == "git" && opts.Protocol == "ssh" && opts.Repo == "" {
+		r := *repoRoot
+		r.Repo = "ssh://git@" + repoRoot.Root
+		repoRoot = &r
+	}
+
+	var dir string
+	var err error
+	if !opts.NoCache && repoRoot.VCS.Cmd == "git" {
+		var release func()
+		dir, release, err = cachedClone(ctx, g.runner, repoRoot)
+		if err != nil {
+			return repoInfo{}, err
+		}
+		defer release()
+	} else {
+		dir, err = ioutil.TempDir("", "genpkgbuild")
+		if err != nil {
+			return repoInfo{}, fmt.Errorf("could not secure a temp dir: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		logger.debugf("cloning into the temp dir: %s", dir)
+
+		if err := backend.clone(ctx, g.runner, repoRoot.VCS, repoRoot.Repo, dir); err != nil {
+			return repoInfo{}, fmt.Errorf("could not clone the repo: %w", err)
+		}
+	}
+
+	if opts.Tag != "" {
+		if repoRoot.VCS.TagSyncCmd == "" {
+			return repoInfo{}, fmt.Errorf("sorry, the tags are not supported for %s", repoRoot.VCS.Name)
+		}
+		if err := g.runner.runVCS(ctx, repoRoot.VCS, dir, repoRoot.VCS.TagSyncCmd, "tag", opts.Tag); err != nil {
+			return repoInfo{}, fmt.Errorf("could not check out the tag: %w", err)
+		}
+	}
+	if opts.Branch != "" {
+		if err := g.runner.runVCS(ctx, repoRoot.VCS, dir, "checkout --force --detach origin/{branch}", "branch", opts.Branch); err != nil {
+			return repoInfo{}, fmt.Errorf("could not check out the branch: %w", err)
+		}
+	}
+	if opts.Commit != "" {
+		if err := g.runner.runVCS(ctx, repoRoot.VCS, dir, "checkout --force --detach {commit}", "commit", opts.Commit); err != nil {
+			return repoInfo{}, fmt.Errorf("could not check out the commit: %w", err)
+		}
+	}
+	return g.readRepo(ctx, dir, repoRoot, pkgPaths)
+}
+
+// readRepo reads the information from the repository checked out in dir, for
+// inspectRepo. The version is derived from the tag if it's given, or from the
+// history.
+func (g *Generator) readRepo(ctx context.Context, dir string, repoRoot *vcs.RepoRoot, pkgPaths []string) (repoInfo, error) {
+	opts := g.opts
+	var version string
+	var err error
+	if opts.Tag != "" {
+		version = tagVersion(opts.Tag)
+	} else {
+		version, err = g.getVersion(ctx, dir, vcsBackends[repoRoot.VCS.Cmd])
+		if err != nil {
+			return repoInfo{}, err
+		}
+	}
+
+	_, err = os.Stat(filepath.Join(dir, ".gitmodules"))
+	submodules := err == nil
+
+	changelog, err := readChangelog(dir)
+	if err != nil {
+		return repoInfo{}, fmt.Errorf("could not read the changelog: %w", err)
+	}
+
+	license, err := detectLicense(dir)
+	if err != nil {
+		return repoInfo{}, fmt.Errorf("could not detect the license: %w", err)
+	}
+
+	pkgDirs := make(map[string]pkgDir)
+	var moduleDirs []string
+	// The commands found under the packages are inspected, too, to be
+	// chosen later.
+	paths := append([]string(nil), pkgPaths...)
+	for i := 0; i < len(paths); i++ {
+		p := paths[i]
+		if _, ok := pkgDirs[p]; ok {
+			continue
+		}
+		pd, err := inspectPkgDir(dir, p)
+		if err != nil {
+			return repoInfo{}, err
+		}
+		if pd.hasModule {
+			moduleDirs = appendUnique(moduleDirs, pd.moduleDir)
+		}
+		paths = append(paths, pd.cmdDirs...)
+		pkgDirs[p] = pd
+	}
+
+	// The dependencies are read from the modules containing the packages,
+	// which may be nested ones.
+	if len(moduleDirs) == 0 {
+		moduleDirs = []string{""}
+	}
+	var depends, optDepends, modRequires []string
+	for _, m := range moduleDirs {
+		ds, err := readGoModDepends(filepath.Join(dir, filepath.FromSlash(m)), opts.Mapping)
+		if err != nil {
+			return repoInfo{}, fmt.Errorf("could not read go.mod: %w", err)
+		}
+		for _, d := range ds.depends {
+			depends = appendUnique(depends, d)
+		}
+		for _, d := range ds.optDepends {
+			optDepends = appendUnique(optDepends, d)
+		}
+		for _, r := range ds.unknown {
+			modRequires = appendUnique(modRequires, r)
+		}
+	}
+
+	if opts.LddDepends && opts.CGO != "off" {
+		ds, err := lddDepends(ctx, dir, pkgDirs, opts.BuildFlags)
+		if err != nil {
+			logger.infof("warning: could not detect the shared libraries: %v", err)
+		}
+		for _, d := range ds {
+			depends = appendUnique(depends, d)
+		}
+	}
+
+	return repoInfo{
+		version:     version,
+		license:     license,
+		depends:     depends,
+		optDepends:  optDepends,
+		modRequires: modRequires,
+		submodules:  submodules,
+		changelog:   changelog,
+		pkgDirs:     pkgDirs,
+	}, nil
+}
+
+// inspectPkgDir reads the information of the directory of the package at the
+// relative path p in the repository cloned into dir.
+func inspectPkgDir(dir, p string) (pkgDir, error) {
+	moduleDir, hasModule, err := findModuleDir(dir, p)
+	if err != nil {
+		return pkgDir{}, fmt.Errorf("could not find go.mod: %w", err)
+	}
+	pd := pkgDir{
+		moduleDir: moduleDir,
+		hasModule: hasModule,
+	}
+	if hasModule {
+		pd.modulePath, err = readModulePath(filepath.Join(dir, filepath.FromSlash(moduleDir)))
+		if err != nil {
+			return pkgDir{}, fmt.Errorf("could not read go.mod: %w", err)
+		}
+	}
+
+	d := filepath.Join(dir, filepath.FromSlash(p))
+	if fi, err := os.Stat(d); err != nil || !fi.IsDir() {
+		return pd, nil
+	}
+	pd.exists = true
+	pd.mainPkg, err = isMainPackage(d)
+	if err != nil {
+		return pkgDir{}, fmt.Errorf("could not read the package: %w", err)
+	}
+	if !pd.mainPkg {
+		pd.cmdDirs, err = findCommands(dir, p)
+		if err != nil {
+			return pkgDir{}, fmt.Errorf("could not find the commands: %w", err)
+		}
+	}
+	return pd, nil
+}
+
+// vcsEnv returns the environment of the VCS commands. git is not allowed to
+// ask the credentials on the terminal, which would be mixed up with the
+// questions, so that it fails instead.
+func vcsEnv() []string {
+	return append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+}
+
+// authFailureMessages is the messages of the VCS commands on the failures of
+// the authentication.
+var authFailureMessages = []string{
+	"terminal prompts disabled",
+	"could not read Username",
+	"Authentication failed",
+	"Permission denied (publickey",
+	"Repository not found",
+	"abort: authorization failed",
+}
+
+// vcsError returns err of the VCS command with the output out, explaining how
+// to authenticate if it failed on the authentication.
+func vcsError(err error, out []byte) error {
+	for _, m := range authFailureMessages {
+		if bytes.Contains(out, []byte(m)) {
+			return fmt.Errorf("%w\nthe authentication failed. For the private repository, set up the credentials for it, like ~/.netrc or a git credential helper, or use -protocol ssh with the SSH agent or ~/.ssh/config", err)
+		}
+	}
+	return err
+}
+
+// pkgVerCmd returns the script printing the version, given by -pkgver-cmd or
+// the default of the VCS.
+func pkgVerCmd(backend vcsBackend, opts Options) string {
+	if opts.PkgVerCmd != "" {
+		return opts.PkgVerCmd
+	}
+	return backend.pkgVerCmd()
+}
+
+// getVersion runs the script of pkgver() for the VCS, or the one given by
+// -pkgver-cmd, in dir and returns the version.
+func (g *Generator) getVersion(ctx context.Context, dir string, backend vcsBackend) (string, error) {
+	version, err := g.runner.runScript(ctx, dir, pkgVerCmd(backend, g.opts))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(version)), nil
+}
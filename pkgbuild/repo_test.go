@@ -0,0 +1,245 @@
+package pkgbuild
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/vcs"
+)
+
+// fakeGit is the shell function replacing git in the scripts run by
+// fakeRunner, printing the canned history given by the environment.
+const fakeGit = `
+git() {
+  case "$1" in
+    describe) [ -n "$FAKE_DESCRIBE" ] && echo "$FAKE_DESCRIBE" || return 128 ;;
+    rev-list) echo "$FAKE_COUNT" ;;
+    rev-parse) echo "$FAKE_SHORT" ;;
+    *) return 1 ;;
+  esac
+}
+`
+
+// fakeRunner is the runner inspecting the canned repository without git and
+// the network. The clone is populated with files, and the scripts see the
+// history of describe, count and short, as "git describe --long --tags", "git
+// rev-list --count HEAD" and "git rev-parse --short HEAD" print them. describe
+// is empty for the repository without tags.
+type fakeRunner struct {
+	files                  map[string]string
+	describe, count, short string
+	// cmdlines is the VCS command lines run, with the keys replaced.
+	cmdlines []string
+}
+
+func (r *fakeRunner) runVCS(ctx context.Context, v *vcs.Cmd, dir, cmdline string, keyval ...string) error {
+	kv := make(map[string]string)
+	for i := 0; i+1 < len(keyval); i += 2 {
+		cmdline = strings.Replace(cmdline, "{"+keyval[i]+"}", keyval[i+1], -1)
+		kv[keyval[i]] = keyval[i+1]
+	}
+	r.cmdlines = append(r.cmdlines, cmdline)
+	if d, ok := kv["dir"]; ok {
+		return writeFiles(d, r.files)
+	}
+	return nil
+}
+
+func (r *fakeRunner) runScript(ctx context.Context, dir, script string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "bash", "-c", fakeGit+script)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "FAKE_DESCRIBE="+r.describe, "FAKE_COUNT="+r.count, "FAKE_SHORT="+r.short)
+	return cmd.Output()
+}
+
+// writeFiles writes the files keyed by the slash-separated paths relative to
+// dir, creating the directories.
+func writeFiles(dir string, files map[string]string) error {
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nestedModuleFiles is the repository with the nested module in "tools".
+var nestedModuleFiles = map[string]string{
+	"go.mod":                    "module github.com/a/foo\n",
+	"foo.go":                    "package foo\n",
+	"cmd/foo/main.go":           "package main\n\nfunc main() {}\n",
+	"tools/go.mod":              "module github.com/a/foo/tools\n",
+	"tools/tools.go":            "package tools\n",
+	"tools/cmd/gen/main.go":     "package main\n\nfunc main() {}\n",
+	"tools/cmd/gen/gen_test.go": "package main_test\n",
+}
+
+func TestFindModuleDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genpkgbuild-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := writeFiles(dir, nestedModuleFiles); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rel       string
+		moduleDir string
+	}{
+		{"", ""},
+		{"cmd/foo", ""},
+		{"tools", "tools"},
+		{"tools/cmd/gen", "tools"},
+		{"tools/missing", "tools"},
+	}
+	for _, tt := range tests {
+		moduleDir, ok, err := findModuleDir(dir, tt.rel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || moduleDir != tt.moduleDir {
+			t.Errorf("findModuleDir(%q) = %q, %v, want %q", tt.rel, moduleDir, ok, tt.moduleDir)
+		}
+	}
+
+	if err := os.Remove(filepath.Join(dir, "go.mod")); err != nil {
+		t.Fatal(err)
+	}
+	if moduleDir, ok, err := findModuleDir(dir, "cmd/foo"); err != nil || ok {
+		t.Errorf("findModuleDir without go.mod = %q, %v, %v", moduleDir, ok, err)
+	}
+}
+
+func TestInspectPkgDirNestedModule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genpkgbuild-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := writeFiles(dir, nestedModuleFiles); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		p    string
+		want pkgDir
+	}{
+		{
+			p:    "",
+			want: pkgDir{exists: true, hasModule: true, modulePath: "github.com/a/foo", cmdDirs: []string{"cmd/foo"}},
+		},
+		{
+			p:    "cmd/foo",
+			want: pkgDir{exists: true, hasModule: true, modulePath: "github.com/a/foo", mainPkg: true},
+		},
+		{
+			p:    "tools",
+			want: pkgDir{exists: true, moduleDir: "tools", hasModule: true, modulePath: "github.com/a/foo/tools", cmdDirs: []string{"tools/cmd/gen"}},
+		},
+		{
+			p:    "tools/cmd/gen",
+			want: pkgDir{exists: true, moduleDir: "tools", hasModule: true, modulePath: "github.com/a/foo/tools", mainPkg: true},
+		},
+		{
+			p:    "tools/missing",
+			want: pkgDir{moduleDir: "tools", hasModule: true, modulePath: "github.com/a/foo/tools"},
+		},
+	}
+	for _, tt := range tests {
+		got, err := inspectPkgDir(dir, tt.p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("inspectPkgDir(%q) = %+v, want %+v", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestGetVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		describe  string
+		pkgVerCmd string
+		want      string
+	}{
+		{name: "no tags", want: "r42.abc1234"},
+		{name: "one tag", describe: "v1.2.0-0-gabc1234", want: "1.2.0.r0.gabc1234"},
+		{name: "tag and commits", describe: "v1.2.0-3-gabc1234", want: "1.2.0.r3.gabc1234"},
+		{name: "pre-release", describe: "v1.2.0-rc.1-3-gabc1234", want: "1.2.0rc.1.r3.gabc1234"},
+		{name: "prefixed", describe: "release-1.2-3-gabc1234", want: "1.2.r3.gabc1234"},
+		{name: "pkgver-cmd", describe: "v1.2.0-3-gabc1234", pkgVerCmd: "echo 9.9", want: "9.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Generator{
+				opts:   Options{PkgVerCmd: tt.pkgVerCmd},
+				runner: &fakeRunner{describe: tt.describe, count: "42", short: "abc1234"},
+			}
+			got, err := g.getVersion(context.Background(), ".", gitBackend{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInspectRepoCachedClone(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "genpkgbuild-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cacheDir)
+	defer os.Setenv("XDG_CACHE_HOME", os.Getenv("XDG_CACHE_HOME"))
+	os.Setenv("XDG_CACHE_HOME", cacheDir)
+
+	r := &fakeRunner{
+		files: map[string]string{
+			"go.mod":          "module github.com/a/foo\n",
+			"cmd/foo/main.go": "package main\n\nfunc main() {}\n",
+		},
+		describe: "v1.2.0-3-gabc1234",
+	}
+	g := &Generator{runner: r}
+	repoRoot := &vcs.RepoRoot{VCS: vcs.ByCmd("git"), Repo: "https://github.com/a/foo", Root: "github.com/a/foo"}
+
+	for i, wantCmds := range [][]string{
+		{"clone https://github.com/a/foo "},
+		{"fetch --prune --tags --force origin", "checkout --force --detach origin/HEAD", "clean -d --force -x"},
+	} {
+		r.cmdlines = nil
+		info, err := g.inspectRepo(context.Background(), repoRoot, []string{"cmd/foo"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.version != "1.2.0.r3.gabc1234" {
+			t.Errorf("#%d: version %q", i, info.version)
+		}
+		if d := info.pkgDirs["cmd/foo"]; !d.exists || !d.mainPkg || d.modulePath != "github.com/a/foo" {
+			t.Errorf("#%d: cmd/foo: %+v", i, d)
+		}
+		if len(r.cmdlines) != len(wantCmds) {
+			t.Fatalf("#%d: run %q, want %q", i, r.cmdlines, wantCmds)
+		}
+		for j, c := range wantCmds {
+			if !strings.HasPrefix(r.cmdlines[j], c) {
+				t.Errorf("#%d: run %q, want %q", i, r.cmdlines[j], c)
+			}
+		}
+	}
+}
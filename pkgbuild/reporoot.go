@@ -0,0 +1,119 @@
+package pkgbuild
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/vcs"
+)
+
+// gitlabAPI is the base URL of the API of GitLab, replaced by the tests with
+// the mock server.
+var gitlabAPI = "https://gitlab.com/api/v4"
+
+// resolveRepoRoot returns the repository of the import path. If root is given
+// by -repo-root, it's used as the import path of the root of the repository.
+func resolveRepoRoot(ctx context.Context, importPath, root string) (*vcs.RepoRoot, error) {
+	if root != "" {
+		if importPath != root && !strings.HasPrefix(importPath, root+"/") {
+			return nil, IncorrectUsageError{fmt.Errorf("the import path is not in -repo-root %s: %s", root, importPath)}
+		}
+		repoRoot, err := repoRootForImportPath(ctx, root)
+		if err != nil {
+			return nil, err
+		}
+		if repoRoot.Root != root {
+			repoRoot = &vcs.RepoRoot{VCS: repoRoot.VCS, Repo: "https://" + root, Root: root}
+		}
+		return repoRoot, nil
+	}
+
+	// The go-get meta tag of GitLab can't tell the project in the subgroups
+	// from its subdirectories, so ask the API.
+	if strings.HasPrefix(importPath, "gitlab.com/") && strings.Count(importPath, "/") > 2 {
+		root, err := gitlabProjectRoot(ctx, importPath)
+		if err == nil {
+			return &vcs.RepoRoot{VCS: vcs.ByCmd("git"), Repo: "https://" + root, Root: root}, nil
+		}
+		logger.debugf("could not find the GitLab project of %s: %v", importPath, err)
+	}
+	return repoRootForImportPath(ctx, importPath)
+}
+
+// repoRootForImportPath is vcs.RepoRootForImportPath supporting Fossil, too.
+func repoRootForImportPath(ctx context.Context, importPath string) (*vcs.RepoRoot, error) {
+	repoRoot, err := vcs.RepoRootForImportPath(importPath, true)
+	if err != nil && strings.Contains(err.Error(), `unknown vcs "fossil"`) {
+		return fossilRepoRoot(ctx, importPath)
+	}
+	return repoRoot, err
+}
+
+// goImportRe matches the go-import meta tag, like <meta name="go-import"
+// content="example.com/foo fossil https://example.com/foo">.
+var goImportRe = regexp.MustCompile(`<meta\s+name=["']go-import["']\s+content=["']([^"']+)["']`)
+
+// fossilRepoRoot returns the Fossil repository of the import path, read from
+// the go-import meta tag, since golang.org/x/tools/go/vcs rejects it as an
+// unknown VCS.
+func fossilRepoRoot(ctx context.Context, importPath string) (*vcs.RepoRoot, error) {
+	var buf bytes.Buffer
+	if err := download(ctx, &buf, "https://"+importPath+"?go-get=1"); err != nil {
+		return nil, err
+	}
+	for _, m := range goImportRe.FindAllStringSubmatch(buf.String(), -1) {
+		f := strings.Fields(m[1])
+		if len(f) != 3 || f[1] != "fossil" {
+			continue
+		}
+		if importPath == f[0] || strings.HasPrefix(importPath, f[0]+"/") {
+			return &vcs.RepoRoot{VCS: vcsFossil, Repo: f[2], Root: f[0]}, nil
+		}
+	}
+	return nil, fmt.Errorf("no go-import meta tag of fossil is found for %s", importPath)
+}
+
+// gitlabProjectRoot returns the import path of the GitLab project containing
+// the import path, trying the longest one first.
+func gitlabProjectRoot(ctx context.Context, importPath string) (string, error) {
+	elems := strings.Split(importPath, "/")
+	for n := len(elems); n >= 3; n-- {
+		project := strings.Join(elems[1:n], "/")
+		ok, err := gitlabProjectExists(ctx, project)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return "gitlab.com/" + project, nil
+		}
+	}
+	return "", errors.New("no project is found")
+}
+
+func gitlabProjectExists(ctx context.Context, project string) (bool, error) {
+	apiURL := fmt.Sprintf("%s/projects/%s", gitlabAPI, url.PathEscape(project))
+	logger.debugf("GET %s", apiURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("could not fetch %s: %s", apiURL, resp.Status)
+}
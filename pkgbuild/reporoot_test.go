@@ -0,0 +1,79 @@
+package pkgbuild
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGitlabProjectRoot(t *testing.T) {
+	projects := map[string]bool{
+		"a/b":     true,
+		"g/sub/p": true,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, "/projects/")
+		switch {
+		case p == "broken/x/y":
+			w.WriteHeader(http.StatusInternalServerError)
+		case projects[p]:
+			w.Write([]byte("{}"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	defer func(api string) { gitlabAPI = api }(gitlabAPI)
+	gitlabAPI = srv.URL
+
+	tests := []struct {
+		importPath string
+		root       string
+		wantErr    bool
+	}{
+		{importPath: "gitlab.com/a/b/cmd/foo", root: "gitlab.com/a/b"},
+		{importPath: "gitlab.com/g/sub/p", root: "gitlab.com/g/sub/p"},
+		{importPath: "gitlab.com/g/sub/p/cmd/foo", root: "gitlab.com/g/sub/p"},
+		{importPath: "gitlab.com/x/y/z", wantErr: true},
+		{importPath: "gitlab.com/broken/x/y", wantErr: true},
+	}
+	for _, tt := range tests {
+		root, err := gitlabProjectRoot(context.Background(), tt.importPath)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("gitlabProjectRoot(%q) = %q, want an error", tt.importPath, root)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("gitlabProjectRoot(%q): %v", tt.importPath, err)
+			continue
+		}
+		if root != tt.root {
+			t.Errorf("gitlabProjectRoot(%q) = %q, want %q", tt.importPath, root, tt.root)
+		}
+	}
+}
+
+func TestResolveRepoRootGitLabSubgroup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/projects/g/sub/p" {
+			w.Write([]byte("{}"))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+	defer func(api string) { gitlabAPI = api }(gitlabAPI)
+	gitlabAPI = srv.URL
+
+	repoRoot, err := resolveRepoRoot(context.Background(), "gitlab.com/g/sub/p/cmd/foo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repoRoot.Root != "gitlab.com/g/sub/p" || repoRoot.Repo != "https://gitlab.com/g/sub/p" || repoRoot.VCS.Cmd != "git" {
+		t.Errorf("got %+v", repoRoot)
+	}
+}
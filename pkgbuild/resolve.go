@@ -0,0 +1,873 @@
+package pkgbuild
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/go/vcs"
+)
+
+// pkgVerRe matches pkgver allowed by makepkg, without hyphens and colons.
+var pkgVerRe = regexp.MustCompile(`^[A-Za-z0-9._+]+$`)
+
+// Validate checks the options not depending on the repository, which are
+// checked again before the repository is inspected. The zero values mean the
+// defaults.
+func (opts Options) Validate() error {
+	switch opts.Format {
+	case "", "pkgbuild", "json":
+	default:
+		return IncorrectUsageError{fmt.Errorf("unknown format: %s", opts.Format)}
+	}
+	switch opts.Protocol {
+	case "", "https", "git", "ssh":
+	default:
+		return IncorrectUsageError{fmt.Errorf("unknown protocol: %s", opts.Protocol)}
+	}
+	if opts.PkgRel < 0 {
+		return IncorrectUsageError{fmt.Errorf("-pkgrel must be a positive integer: %d", opts.PkgRel)}
+	}
+	if opts.Epoch < 0 {
+		return IncorrectUsageError{fmt.Errorf("-epoch must be a non-negative integer: %d", opts.Epoch)}
+	}
+	if strings.ContainsRune(opts.Changelog, '/') {
+		return IncorrectUsageError{fmt.Errorf("-changelog must be a file name next to the PKGBUILD: %s", opts.Changelog)}
+	}
+	if opts.Timeout < 0 {
+		return IncorrectUsageError{fmt.Errorf("-timeout must not be negative: %s", opts.Timeout)}
+	}
+	for _, b := range opts.Backup {
+		if strings.HasPrefix(b, "/") {
+			return IncorrectUsageError{fmt.Errorf("-backup must be relative to /, without the leading slash: %s", b)}
+		}
+	}
+	if strings.ContainsRune(opts.Install, '/') {
+		return IncorrectUsageError{fmt.Errorf("-install must be a file name next to the PKGBUILD: %s", opts.Install)}
+	}
+	if opts.PkgVer != "" && !pkgVerRe.MatchString(opts.PkgVer) {
+		return IncorrectUsageError{fmt.Errorf("-pkgver must consist of alphanumerics and \"._+\": %s", opts.PkgVer)}
+	}
+	if strings.ContainsAny(opts.Branch, " \t\n#") || strings.HasPrefix(opts.Branch, "-") {
+		return IncorrectUsageError{fmt.Errorf("invalid -branch: %q", opts.Branch)}
+	}
+	if strings.ContainsAny(opts.Dir, "/ \t\n") || opts.Dir == "." || opts.Dir == ".." {
+		return IncorrectUsageError{fmt.Errorf("-dir must be a directory name without slashes and spaces: %q", opts.Dir)}
+	}
+	if opts.BinDir != "" && !path.IsAbs(opts.BinDir) {
+		return IncorrectUsageError{fmt.Errorf("-bindir must be an absolute path: %s", opts.BinDir)}
+	}
+	switch opts.CGO {
+	case "", "on", "off":
+	default:
+		return IncorrectUsageError{fmt.Errorf("-cgo must be on or off: %s", opts.CGO)}
+	}
+	switch opts.GO111Module {
+	case "", "on", "off", "auto", "none":
+	default:
+		return IncorrectUsageError{fmt.Errorf("-go111module must be on, off, auto or none: %s", opts.GO111Module)}
+	}
+	if strings.ContainsAny(opts.GoToolchain, " \t\n") {
+		return IncorrectUsageError{fmt.Errorf("invalid -gotoolchain: %q", opts.GoToolchain)}
+	}
+	if opts.Guidelines && opts.CGO == "off" {
+		return IncorrectUsageError{errors.New("-guidelines can't be used with -cgo off, since it links externally")}
+	}
+	for _, a := range opts.Arch {
+		if a == "any" && len(opts.Arch) > 1 {
+			return IncorrectUsageError{errors.New("-arch any can't be combined with the other architectures")}
+		}
+	}
+	if opts.ServiceType != "" && !serviceTypes[opts.ServiceType] {
+		return IncorrectUsageError{fmt.Errorf("unknown -systemd-type: %s", opts.ServiceType)}
+	}
+	return nil
+}
+
+// resolveData resolves the values to be rendered into PKGBUILD for the import
+// paths, by asking the user and reading from the repository.
+func (g *Generator) resolveData(ctx context.Context, args []string) (TmplData, error) {
+	if err := g.opts.Validate(); err != nil {
+		return TmplData{}, err
+	}
+	opts := g.opts
+	args, version, err := splitImportVersions(args)
+	if err != nil {
+		return TmplData{}, err
+	}
+	switch version {
+	case "":
+	case "latest":
+		opts.PkgVerFromGoMod = true
+	default:
+		if opts.Tag != "" && opts.Tag != version {
+			return TmplData{}, IncorrectUsageError{fmt.Errorf("the version of the import path %s differs from -tag %s", version, opts.Tag)}
+		}
+		opts.Tag = version
+	}
+	args, local, err := resolveLocalPaths(ctx, args)
+	if err != nil {
+		return TmplData{}, err
+	}
+	importPath := args[0]
+
+	var repoRoot *vcs.RepoRoot
+	if local != nil {
+		if opts.RepoRoot != "" || opts.Branch != "" || opts.Commit != "" {
+			return TmplData{}, IncorrectUsageError{errors.New("-repo-root, -branch and -commit can't be used with the local directory, which is read as it's checked out")}
+		}
+		repoRoot = local.repoRoot
+		// The remote differing from the import path is used as the
+		// source, like -repo, with https as the default protocol.
+		if p := remoteRepoPath(local.remote); opts.Repo == "" && p != "" && p != repoRoot.Root {
+			opts.Repo = "https://" + p
+		}
+		opts.localDir = local.dir
+	} else {
+		repoRoot, err = resolveRepoRoot(ctx, importPath, opts.RepoRoot)
+		if err != nil {
+			return TmplData{}, fmt.Errorf("can't get root repo for the import path: %w", err)
+		}
+	}
+	if opts.Repo != "" {
+		// The repository is fetched from the mirror, but built with the
+		// original import path.
+		r := *repoRoot
+		r.Repo = opts.Repo
+		repoRoot = &r
+	}
+	logger.debugf("the repository root of %s: %s (%s %s)", importPath, repoRoot.Root, repoRoot.VCS.Cmd, repoRoot.Repo)
+
+	if opts.PkgVerFromGoMod {
+		if opts.Tag == "" {
+			opts.Tag, err = latestModuleVersion(ctx, repoRoot.Root)
+			if err != nil {
+				return TmplData{}, fmt.Errorf("could not find the module version: %w", err)
+			}
+			logger.infof("packaging the release %s", opts.Tag)
+		} else if !semver.IsValid(opts.Tag) {
+			return TmplData{}, IncorrectUsageError{fmt.Errorf("the tag is not a valid module version: %s", opts.Tag)}
+		}
+	}
+	if opts.SourceZip && opts.Tag == "" {
+		return TmplData{}, IncorrectUsageError{errors.New("-source-zip requires -tag, -pkgver-from-gomod or the version of the import path")}
+	}
+	if opts.Reproducible {
+		if opts.Tag == "" {
+			return TmplData{}, IncorrectUsageError{errors.New("-reproducible requires -tag, -pkgver-from-gomod or the version of the import path, to fix the source")}
+		}
+		opts.TrimPath = true
+		opts.ModDownload = true
+	}
+	buildFlags := opts.BuildFlags
+	if opts.TrimPath {
+		buildFlags = append([]string{"-trimpath"}, buildFlags...)
+	}
+	// The methods called below see the tag given by the import paths or found
+	// in the module proxies.
+	ng := *g
+	ng.opts = opts
+	g = &ng
+
+	backend, ok := vcsBackends[repoRoot.VCS.Cmd]
+	if !ok {
+		return TmplData{}, fmt.Errorf("sorry, the VCS is not supported yet: %s", repoRoot.VCS.Name)
+	}
+
+	baseName := path.Base(repoRoot.Root)
+	dir := baseName
+	source := backend.source(repoRoot, opts.Protocol)
+	if opts.Repo != "" {
+		source = vcsSource(repoRoot.VCS.Cmd, opts.Repo)
+	}
+	makeDepends := backend.makeDepends()
+	defaultPkgName := fmt.Sprintf("%s-%s", baseName, repoRoot.VCS.Cmd)
+	if opts.SourceZip {
+		var zipURL string
+		zipURL, dir, err = proxyZipSource(repoRoot.Root, opts.Tag)
+		if err != nil {
+			return TmplData{}, err
+		}
+		source = fmt.Sprintf("%s-%s.zip::%s", baseName, tagVersion(opts.Tag), zipURL)
+		makeDepends = nil
+		defaultPkgName = baseName
+	} else if opts.Tag != "" {
+		var tarballURL string
+		tarballURL, dir, err = tarballSource(repoRoot.Root, opts.Tag)
+		if err != nil {
+			return TmplData{}, err
+		}
+		source = fmt.Sprintf("%s-%s.tar.gz::%s", baseName, tagVersion(opts.Tag), tarballURL)
+		makeDepends = nil
+		defaultPkgName = baseName
+	}
+	if opts.Branch != "" {
+		if opts.Tag != "" || repoRoot.VCS.Cmd != "git" {
+			return TmplData{}, IncorrectUsageError{errors.New("-branch requires the git source, without -tag")}
+		}
+		source += "#branch=" + opts.Branch
+	}
+	if opts.Commit != "" {
+		if opts.Tag != "" || opts.Branch != "" || repoRoot.VCS.Cmd != "git" {
+			return TmplData{}, IncorrectUsageError{errors.New("-commit requires the git source, without -tag and -branch")}
+		}
+		if !commitRe.MatchString(opts.Commit) {
+			return TmplData{}, IncorrectUsageError{fmt.Errorf("-commit must be the hash of the commit, like 0123abc: %s", opts.Commit)}
+		}
+		source += "#commit=" + opts.Commit
+	}
+	if opts.Submodules && (opts.Tag != "" || repoRoot.VCS.Cmd != "git") {
+		return TmplData{}, IncorrectUsageError{errors.New("-submodules requires the git source, without -tag")}
+	}
+	if opts.Dir != "" {
+		if opts.Tag != "" {
+			return TmplData{}, IncorrectUsageError{errors.New("-dir can't be used with -tag, since the directory is the one in the archive")}
+		}
+		dir = opts.Dir
+	}
+	if opts.NamedSource && opts.Tag != "" {
+		return TmplData{}, IncorrectUsageError{errors.New("-named-source can't be used with -tag, since the archive is extracted as is")}
+	}
+	// makepkg clones the repository into the directory named by the prefix
+	// of the source.
+	namedSource := opts.NamedSource || opts.Dir != ""
+	if opts.Tag == "" && !namedSource && vcsCloneDir(source) != dir {
+		// e.g. the mirror given by -repo, named differently.
+		logger.debugf("naming the source, since it's cloned into %s, not %s", vcsCloneDir(source), dir)
+		namedSource = true
+	}
+
+	for _, m := range opts.Modes {
+		if !modeRe.MatchString(m) {
+			return TmplData{}, IncorrectUsageError{fmt.Errorf("the mode must be an octal number like 0755: %s", m)}
+		}
+	}
+	var relPaths []string
+	for _, p := range args {
+		relPath, err := relImportPath(repoRoot.Root, p)
+		if err != nil {
+			return TmplData{}, err
+		}
+		relPaths = append(relPaths, relPath)
+	}
+
+	// On the early return, the clone is aborted and the goroutine is waited
+	// for, to clean up the temp dir.
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	errC := make(chan error, 1)
+	infoC := make(chan repoInfo, 1)
+	go func() {
+		defer close(done)
+
+		// The timeout covers the clone and the commands run in it, which may
+		// hang on the network.
+		inspectCtx := ctx
+		if opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			inspectCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			defer cancel()
+		}
+		info, err := g.inspectRepo(inspectCtx, repoRoot, relPaths)
+		if err != nil && inspectCtx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s reading the repository: %w", opts.Timeout, err)
+		}
+		if err == nil {
+			var sum string
+			sum, err = sourceSHA256(ctx, source)
+			info.sha256sums = []string{sum}
+		}
+		if err == nil {
+			// The description is only a suggestion. Don't fail on it.
+			info.description, _ = fetchDescription(ctx, repoRoot.Root)
+		}
+		errC <- err
+		infoC <- info
+	}()
+
+	pkgName := opts.PkgName
+	if pkgName != "" {
+		if err := checkPkgName(pkgName); err != nil {
+			return TmplData{}, invalidPkgNameError("-pkgname", pkgName, err)
+		}
+	}
+	for _, group := range opts.Groups {
+		if err := checkPkgName(group); err != nil {
+			return TmplData{}, IncorrectUsageError{fmt.Errorf("invalid -groups: %w", err)}
+		}
+	}
+	if opts.PkgBase != "" {
+		if !opts.Split {
+			return TmplData{}, IncorrectUsageError{errors.New("-pkgbase requires -split")}
+		}
+		if err := checkPkgName(opts.PkgBase); err != nil {
+			return TmplData{}, invalidPkgNameError("-pkgbase", opts.PkgBase, err)
+		}
+	}
+	defaultPkgName = sanitizePkgName(defaultPkgName)
+	for pkgName == "" {
+		pkgName, err = g.prompt(ctx, "Package Name", defaultPkgName)
+		if err != nil {
+			return TmplData{}, err
+		}
+		if err := checkPkgName(pkgName); err != nil {
+			// The default is given back on EOF, so asking it again never
+			// ends.
+			if g.scn == nil || pkgName == defaultPkgName {
+				return TmplData{}, err
+			}
+			// Ask again suggesting the valid one, or nothing if all the
+			// characters are invalid.
+			fmt.Fprintln(g.w, err)
+			pkgName, defaultPkgName = "", sanitizePkgName(pkgName)
+		}
+	}
+
+	maintainer := opts.Maintainer
+	if maintainer == "" {
+		maintainer, err = g.prompt(ctx, "Maintainer", gitConfigMaintainer())
+		if err != nil {
+			return TmplData{}, err
+		}
+	}
+
+	g.notice("Please wait...")
+
+	if err := <-errC; err != nil {
+		return TmplData{}, err
+	}
+	info := <-infoC
+	g.notice("\n")
+	if info.submodules && !opts.Submodules && opts.Tag == "" {
+		logger.infof("warning: the repository has git submodules. Specify -submodules to check out them in prepare().")
+	}
+
+	args, relPaths, err = g.selectCommands(ctx, repoRoot.Root, args, relPaths, info)
+	if err != nil {
+		return TmplData{}, err
+	}
+	// The binary names and the modes are counted for the commands chosen for
+	// the import paths, e.g. all of the "cmd" directory of the root.
+	if len(opts.BinNames) > len(args) {
+		return TmplData{}, IncorrectUsageError{errors.New("more binary names than the commands are given")}
+	}
+	if len(opts.Modes) > len(args) && len(opts.Modes) > 1 {
+		return TmplData{}, IncorrectUsageError{errors.New("more modes than the commands are given")}
+	}
+
+	library := opts.Library || isLibrary(relPaths, info)
+	if library {
+		if opts.Split {
+			return TmplData{}, IncorrectUsageError{errors.New("-split can't be used for a library")}
+		}
+		logger.infof("%s has no command. The source is packaged as a library without binaries.", strings.Join(args, " "))
+		// No binary is asked and built for the library.
+		args, relPaths = nil, nil
+	}
+
+	binDir := "/usr/bin"
+	if opts.BinDir != "" {
+		binDir = path.Clean(opts.BinDir)
+	}
+	go111Module := opts.GO111Module
+	if go111Module == "none" {
+		go111Module = ""
+	}
+	var binaries []Binary
+	for i, p := range args {
+		defaultBinName, relPath := binNameOf(p, relPaths[i], info.pkgDirs[relPaths[i]])
+
+		// The binary is often named after the module rather than the
+		// directory of package main, so offer the name, too.
+		var altBinName string
+		if d := info.pkgDirs[relPaths[i]]; d.mainPkg && d.modulePath != "" {
+			if prefix, _ := splitMajorVersion(d.modulePath); path.Base(prefix) != defaultBinName {
+				altBinName = path.Base(prefix)
+			}
+		}
+
+		var binName string
+		if i < len(opts.BinNames) {
+			binName = opts.BinNames[i]
+		} else {
+			q := "Binary name to be installed"
+			if len(args) > 1 {
+				q = fmt.Sprintf("Binary name to be installed for %s", p)
+			}
+			if altBinName != "" {
+				q = fmt.Sprintf("%s(%s or %s)", q, defaultBinName, altBinName)
+			}
+			binName, err = g.prompt(ctx, q, defaultBinName)
+			if err != nil {
+				return TmplData{}, err
+			}
+		}
+
+		// "go build" writes nothing for the package which is not a command,
+		// and package() fails at installing it.
+		if d, ok := info.pkgDirs[relPath]; ok && (!d.exists || !d.mainPkg) {
+			msg := fmt.Sprintf("%s is not package main, so no binary is built for it", p)
+			if !d.exists {
+				msg = fmt.Sprintf("%s is not found in the repository", p)
+			}
+			if opts.Strict {
+				return TmplData{}, errors.New(msg)
+			}
+			logger.infof("warning: %s", msg)
+		}
+
+		// The package is built in the directory of its module, so that the
+		// nested module is not mistaken for the parent one.
+		modDir, pkg := relPath, "."
+		if d := info.pkgDirs[relPaths[i]]; d.hasModule {
+			if opts.SourceZip && d.moduleDir != "" {
+				return TmplData{}, fmt.Errorf("the zip of the module doesn't contain the nested module: %s", p)
+			}
+			modDir = d.moduleDir
+			if rel := strings.TrimPrefix(strings.TrimPrefix(relPath, modDir), "/"); rel != "" {
+				pkg = "./" + rel
+			}
+		}
+
+		mode := "755"
+		if len(opts.Modes) == 1 {
+			mode = opts.Modes[0]
+		} else if i < len(opts.Modes) {
+			mode = opts.Modes[i]
+		}
+
+		binaries = append(binaries, Binary{
+			Name:   binName,
+			Path:   relPath,
+			ModDir: modDir,
+			Pkg:    pkg,
+			Mode:   mode,
+		})
+	}
+
+	provides, conflicts := []string(opts.Provides), []string(opts.Conflicts)
+	if name, ok := trimVCSSuffix(pkgName); ok {
+		if len(provides) == 0 {
+			provides = []string{name}
+		}
+		if len(conflicts) == 0 {
+			conflicts = []string{name}
+		}
+	}
+
+	pkgDesc := opts.PkgDesc
+	if pkgDesc == "" {
+		pkgDesc, err = g.prompt(ctx, "Description", info.description)
+		if err != nil {
+			return TmplData{}, err
+		}
+	}
+
+	depends := []string(opts.Depends)
+	if len(depends) > 0 {
+		if err := checkDepends(depends); err != nil {
+			return TmplData{}, IncorrectUsageError{fmt.Errorf("invalid -depends: %w", err)}
+		}
+	}
+	if len(depends) == 0 {
+		dflt := strings.Join(info.depends, " ")
+		for {
+			dependsList, err := g.prompt(ctx, "Dependent Packages(split by space)", dflt)
+			if err != nil {
+				return TmplData{}, err
+			}
+			depends = strings.Fields(dependsList)
+			err = checkDepends(depends)
+			if err == nil {
+				break
+			}
+			// The default is given back on EOF, so asking it again never
+			// ends.
+			if g.scn == nil || dependsList == dflt {
+				return TmplData{}, err
+			}
+			// Ask again with the answer, to be fixed.
+			fmt.Fprintln(g.w, err)
+			dflt = dependsList
+		}
+	}
+	depends = sortUnique(depends)
+
+	var optDepends []string
+	if len(opts.OptDepends) == 0 {
+		optDepends = info.optDepends
+	}
+	for _, d := range opts.OptDepends {
+		d, err := g.optDepend(ctx, d)
+		if err != nil {
+			return TmplData{}, err
+		}
+		optDepends = append(optDepends, d)
+	}
+
+	var manPages []InstallFile
+	for _, src := range opts.ManPages {
+		f, err := manPageFile(src)
+		if err != nil {
+			return TmplData{}, IncorrectUsageError{err}
+		}
+		manPages = append(manPages, f)
+	}
+
+	var completions []InstallFile
+	for _, src := range opts.Completions {
+		f, err := completionFile(src)
+		if err != nil {
+			return TmplData{}, IncorrectUsageError{err}
+		}
+		completions = append(completions, f)
+	}
+
+	licenses := []string(opts.Licenses)
+	if len(licenses) == 0 {
+		licenseList, err := g.prompt(ctx, "Licenses(split by space)", info.license)
+		if err != nil {
+			return TmplData{}, err
+		}
+		licenses = strings.Fields(licenseList)
+	}
+	if len(licenses) == 0 {
+		licenses = []string{"unknown"}
+	}
+
+	arch := []string(opts.Arch)
+	if len(arch) == 0 {
+		arch = []string{"x86_64"}
+		if library {
+			arch = []string{"any"}
+		}
+	}
+
+	ldflags := opts.LDFlags
+	if opts.Strip {
+		// Before -X, which may be given by -ldflags too, to keep its order.
+		ldflags = strings.TrimSpace("-s -w " + ldflags)
+		if opts.VersionVar != "" || strings.Contains(opts.LDFlags, "-X") {
+			logger.infof("warning: -s -w of -strip is placed before -X in -ldflags")
+		}
+		if opts.Guidelines {
+			logger.infof("warning: -strip drops the debug symbols, so makepkg can't make the debug package")
+		}
+	}
+	if opts.VersionVar != "" {
+		ldflags = strings.TrimSpace(fmt.Sprintf("%s -X %s=$pkgver", ldflags, opts.VersionVar))
+	}
+	if opts.Reproducible {
+		// The build ID differs by the environment.
+		ldflags = strings.TrimSpace(ldflags + " -buildid=")
+	}
+
+	data := TmplData{
+		PkgName:           pkgName,
+		PkgDesc:           pkgDesc,
+		Maintainer:        maintainer,
+		Contributors:      opts.Contributors,
+		Dir:               dir,
+		PkgVer:            info.version,
+		PkgRel:            opts.PkgRel,
+		Epoch:             opts.Epoch,
+		Tag:               opts.Tag,
+		VCS:               repoRoot.VCS.Cmd,
+		PkgVerCmd:         pkgVerCmd(backend, opts),
+		Repo:              repoRoot.Repo,
+		Arch:              arch,
+		License:           licenses,
+		Groups:            opts.Groups,
+		Root:              repoRoot.Root,
+		Protocol:          opts.Protocol,
+		Depends:           depends,
+		OptDepends:        optDepends,
+		ModRequires:       info.modRequires,
+		LDFlags:           ldflags,
+		CGO:               opts.CGO,
+		GO111Module:       go111Module,
+		GoToolchain:       opts.GoToolchain,
+		BuildFlags:        buildFlags,
+		Library:           library,
+		ModDownload:       opts.ModDownload,
+		Submodules:        opts.Submodules,
+		Guidelines:        opts.Guidelines,
+		ModDirs:           modDirs(binaries),
+		Binaries:          binaries,
+		BinDir:            binDir,
+		ManPages:          manPages,
+		Completions:       completions,
+		Source:            source,
+		NamedSource:       namedSource,
+		Sha256Sums:        info.sha256sums,
+		GoPkg:             opts.GoPkg,
+		MakeDepends:       mergeMakeDepends(opts.GoPkg, makeDepends, opts.MakeDepends),
+		Provides:          provides,
+		Conflicts:         conflicts,
+		Replaces:          opts.Replaces,
+		Backup:            opts.Backup,
+		Install:           opts.Install,
+		Changelog:         opts.Changelog,
+		UpstreamChangelog: info.changelog,
+	}
+	if opts.Systemd {
+		if len(binaries) == 0 {
+			return TmplData{}, IncorrectUsageError{errors.New("-systemd requires a binary to run")}
+		}
+		data.Service = binaries[0].Name + ".service"
+		data.Sha256Sums = append(data.Sha256Sums, serviceSHA256(ServiceUnit(data, opts)))
+	}
+	if opts.Split {
+		data.PkgBase = opts.PkgBase
+		if data.PkgBase == "" {
+			data.PkgBase = pkgName
+		}
+		data.SplitPkgs = splitPackages(data, opts.Provides, opts.Conflicts)
+		data.Provides, data.Conflicts, data.Replaces = nil, nil, nil
+	}
+	return data, nil
+}
+
+// commitRe matches the full or abbreviated hash of the git commit.
+var commitRe = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// modeRe matches the file mode passed to "install -m".
+var modeRe = regexp.MustCompile(`^[0-7]{3,4}$`)
+
+// splitImportVersions strips the version suffixes like "@v1.2.3" or "@latest"
+// off the import paths, as "go install" accepts them, and returns the version.
+// The versions must be the same if given to multiple import paths.
+func splitImportVersions(args []string) ([]string, string, error) {
+	var paths []string
+	var version string
+	for _, a := range args {
+		p, v := a, ""
+		if i := strings.LastIndex(a, "@"); i >= 0 {
+			p, v = a[:i], a[i+1:]
+			if v != "latest" && !semver.IsValid(v) {
+				return nil, "", IncorrectUsageError{fmt.Errorf("the version must be a module version like v1.2.3, or latest: %s", a)}
+			}
+		}
+		if v != "" {
+			if version != "" && version != v {
+				return nil, "", IncorrectUsageError{fmt.Errorf("the versions of the import paths differ: %s and %s", version, v)}
+			}
+			version = v
+		}
+		paths = append(paths, p)
+	}
+	return paths, version, nil
+}
+
+// relImportPath returns the import path p relative to the root of the
+// repository, which is "" for the root itself. The root, e.g. of the vanity
+// import path, has to be a prefix of p by the path elements.
+func relImportPath(root, p string) (string, error) {
+	if p == root {
+		return "", nil
+	}
+	rel := strings.TrimPrefix(p, root+"/")
+	if rel == p || path.Clean(rel) != rel || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("the import path is not in the repository %s: %s", root, p)
+	}
+	return rel, nil
+}
+
+// splitPackages splits the package into the one for each binary, named like
+// the binary with the VCS suffix of the package name. The completions, the
+// replaces and the provides and conflicts given by the user go to the first
+// package, and the man pages go to the package named like "foo-docs".
+func splitPackages(data TmplData, provides, conflicts []string) []SplitPkg {
+	name, hasSuffix := trimVCSSuffix(data.PkgName)
+	suffix := strings.TrimPrefix(data.PkgName, name)
+
+	var pkgs []SplitPkg
+	for _, b := range data.Binaries {
+		pkg := SplitPkg{
+			Name:     b.Name + suffix,
+			Binaries: []Binary{b},
+			BinDir:   data.BinDir,
+		}
+		if hasSuffix {
+			pkg.Provides = []string{b.Name}
+			pkg.Conflicts = []string{b.Name}
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	if len(pkgs) > 0 {
+		pkgs[0].Completions = data.Completions
+		pkgs[0].Service = data.Service
+		if len(provides) > 0 {
+			pkgs[0].Provides = provides
+		}
+		if len(conflicts) > 0 {
+			pkgs[0].Conflicts = conflicts
+		}
+		pkgs[0].Replaces = data.Replaces
+	}
+	if len(data.ManPages) > 0 {
+		pkgs = append(pkgs, SplitPkg{
+			Name:     name + "-docs" + suffix,
+			BinDir:   data.BinDir,
+			ManPages: data.ManPages,
+		})
+	}
+	return pkgs
+}
+
+// isLibrary reports whether none of the packages is a command, i.e. package
+// main. The packages which are not found are not judged.
+func isLibrary(relPaths []string, info repoInfo) bool {
+	for _, p := range relPaths {
+		if d := info.pkgDirs[p]; !d.exists || d.mainPkg {
+			return false
+		}
+	}
+	return len(relPaths) > 0
+}
+
+// modDirs returns the distinct directories of the modules containing the
+// binaries, in the order of the binaries.
+func modDirs(binaries []Binary) []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, b := range binaries {
+		if !seen[b.ModDir] {
+			seen[b.ModDir] = true
+			dirs = append(dirs, b.ModDir)
+		}
+	}
+	return dirs
+}
+
+// selectCommands replaces the import paths of the packages which are not package
+// main, like the root of the repository, with the commands under "cmd" in them.
+// If there are multiple commands, the user is asked to choose them.
+func (g *Generator) selectCommands(ctx context.Context, root string, args, relPaths []string, info repoInfo) ([]string, []string, error) {
+	var selectedArgs, selectedRelPaths []string
+	for i, p := range args {
+		d := info.pkgDirs[relPaths[i]]
+		if !d.exists || d.mainPkg || len(d.cmdDirs) == 0 {
+			selectedArgs = append(selectedArgs, p)
+			selectedRelPaths = append(selectedRelPaths, relPaths[i])
+			continue
+		}
+
+		cmds := d.cmdDirs
+		if len(cmds) > 1 {
+			var nums []string
+			for n, c := range cmds {
+				if g.scn != nil {
+					fmt.Fprintf(g.w, "%d) %s\n", n+1, path.Join(root, c))
+				}
+				nums = append(nums, strconv.Itoa(n+1))
+			}
+			answer, err := g.prompt(ctx, fmt.Sprintf("Commands to be built in %s(split by space)", p), strings.Join(nums, " "))
+			if err != nil {
+				return nil, nil, err
+			}
+			cmds = nil
+			for _, s := range strings.Fields(answer) {
+				n, err := strconv.Atoi(s)
+				if err != nil || n < 1 || len(d.cmdDirs) < n {
+					return nil, nil, fmt.Errorf("invalid choice of the command: %s", s)
+				}
+				cmds = append(cmds, d.cmdDirs[n-1])
+			}
+		}
+		for _, c := range cmds {
+			selectedArgs = append(selectedArgs, path.Join(root, c))
+			selectedRelPaths = append(selectedRelPaths, c)
+		}
+	}
+	return selectedArgs, selectedRelPaths, nil
+}
+
+// optDepend normalizes the optional dependency into the form of "pkg: reason".
+// If the reason is missing, the user is asked for it.
+func (g *Generator) optDepend(ctx context.Context, d string) (string, error) {
+	pkg, reason := d, ""
+	if i := strings.Index(d, ":"); i >= 0 {
+		pkg, reason = d[:i], d[i+1:]
+	}
+	pkg, reason = strings.TrimSpace(pkg), strings.TrimSpace(reason)
+	if pkg == "" {
+		return "", IncorrectUsageError{fmt.Errorf("the package name is missing in the optional dependency: %s", d)}
+	}
+	if reason == "" {
+		var err error
+		reason, err = g.prompt(ctx, fmt.Sprintf("Reason for the optional dependency %s", pkg), "")
+		if err != nil {
+			return "", err
+		}
+	}
+	if reason == "" {
+		return "", IncorrectUsageError{fmt.Errorf("the reason is missing in the optional dependency, in the form of \"pkg: reason\": %s", d)}
+	}
+	return fmt.Sprintf("%s: %s", pkg, reason), nil
+}
+
+// gitConfigMaintainer returns the user configured in git config, in the form
+// of "Name <email>". It returns "" if the name is not configured.
+func gitConfigMaintainer() string {
+	name, err := exec.Command("git", "config", "--get", "user.name").Output()
+	if err != nil || len(bytes.TrimSpace(name)) == 0 {
+		return ""
+	}
+	email, err := exec.Command("git", "config", "--get", "user.email").Output()
+	if err != nil || len(bytes.TrimSpace(email)) == 0 {
+		return string(bytes.TrimSpace(name))
+	}
+	return fmt.Sprintf("%s <%s>", bytes.TrimSpace(name), bytes.TrimSpace(email))
+}
+
+// mergeMakeDepends returns the build-time dependencies in the lists, sorted and
+// without the duplicates. goPkg is excluded since it's always the first one.
+func mergeMakeDepends(goPkg string, lists ...[]string) []string {
+	var pkgs []string
+	for _, l := range lists {
+		for _, p := range l {
+			if p != goPkg {
+				pkgs = appendUnique(pkgs, p)
+			}
+		}
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// binNameOf returns the default binary name of the import path p, which is the
+// directory d at relPath in the repository, and the path of the package to be
+// built. The major version suffix like "v2" is skipped for the name, and for
+// the path too if it's not a directory, unless it's the directory of the
+// package literally named so.
+func binNameOf(p, relPath string, d pkgDir) (string, string) {
+	prefix, major := splitMajorVersion(p)
+	switch {
+	case major == "":
+	case !d.exists:
+		// The major version is developed in a branch, so the suffix is not
+		// a directory.
+		if relPath = path.Dir(relPath); relPath == "." {
+			relPath = ""
+		}
+		return path.Base(prefix), relPath
+	case strings.HasSuffix(d.modulePath, "/"+major):
+		// The major version is developed in a subdirectory.
+		return path.Base(prefix), relPath
+	}
+	return path.Base(p), relPath
+}
@@ -0,0 +1,244 @@
+package pkgbuild
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckDepends(t *testing.T) {
+	tests := []struct {
+		depend string
+		valid  bool
+	}{
+		{"foo", true},
+		{"lib32-foo", true},
+		{"foo>=1.0", true},
+		{"foo<2", true},
+		{"foo=1:1.0-1", true},
+		{"foo>1.0.r12.gabc123", true},
+		{"c++utils", true},
+		{"Foo", false},
+		{"-foo", false},
+		{".foo", false},
+		{"foo>=", false},
+		{"foo>=1.0-", false},
+		{"foo==1.0", false},
+		{"foo bar", false},
+		{"foo,bar", false},
+	}
+	for _, tt := range tests {
+		err := checkDepends([]string{tt.depend})
+		if tt.valid && err != nil {
+			t.Errorf("checkDepends(%q): %v", tt.depend, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("checkDepends(%q) succeeded, want an error", tt.depend)
+		}
+	}
+}
+
+func TestBinNameOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		p        string
+		relPath  string
+		d        pkgDir
+		binName  string
+		buildDir string
+	}{
+		{
+			name:     "no suffix",
+			p:        "github.com/a/foo/cmd/foo",
+			relPath:  "cmd/foo",
+			d:        pkgDir{exists: true, modulePath: "github.com/a/foo"},
+			binName:  "foo",
+			buildDir: "cmd/foo",
+		},
+		{
+			name:     "v2 in the branch",
+			p:        "github.com/a/foo/v2",
+			relPath:  "v2",
+			d:        pkgDir{modulePath: "github.com/a/foo/v2"},
+			binName:  "foo",
+			buildDir: "",
+		},
+		{
+			name:     "v10 in the branch",
+			p:        "github.com/a/foo/v10",
+			relPath:  "v10",
+			d:        pkgDir{modulePath: "github.com/a/foo/v10"},
+			binName:  "foo",
+			buildDir: "",
+		},
+		{
+			name:     "v2 in the subdirectory",
+			p:        "github.com/a/foo/v2",
+			relPath:  "v2",
+			d:        pkgDir{exists: true, modulePath: "github.com/a/foo/v2"},
+			binName:  "foo",
+			buildDir: "v2",
+		},
+		{
+			name:     "directory named v2",
+			p:        "github.com/a/foo/cmd/v2",
+			relPath:  "cmd/v2",
+			d:        pkgDir{exists: true, modulePath: "github.com/a/foo"},
+			binName:  "v2",
+			buildDir: "cmd/v2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binName, buildDir := binNameOf(tt.p, tt.relPath, tt.d)
+			if binName != tt.binName || buildDir != tt.buildDir {
+				t.Errorf("got %q, %q, want %q, %q", binName, buildDir, tt.binName, tt.buildDir)
+			}
+		})
+	}
+}
+
+func TestRelImportPath(t *testing.T) {
+	tests := []struct {
+		root, p string
+		rel     string
+		wantErr bool
+	}{
+		{root: "github.com/a/foo", p: "github.com/a/foo", rel: ""},
+		{root: "github.com/a/foo", p: "github.com/a/foo/cmd/foo", rel: "cmd/foo"},
+		{root: "golang.org/x/tools", p: "golang.org/x/tools/cmd/godoc", rel: "cmd/godoc"},
+		{root: "go.example.com/foo", p: "go.example.com/foo/v2/cmd/foo", rel: "v2/cmd/foo"},
+		{root: "github.com/a/foo", p: "github.com/a/foobar", wantErr: true},
+		{root: "github.com/a/foo", p: "github.com/a/bar", wantErr: true},
+		{root: "golang.org/x/tools", p: "golang.org/x/toolsx/cmd", wantErr: true},
+		{root: "github.com/a/foo", p: "github.com/a/foo/../bar", wantErr: true},
+		{root: "github.com/a/foo", p: "github.com/a/foo/cmd//foo", wantErr: true},
+	}
+	for _, tt := range tests {
+		rel, err := relImportPath(tt.root, tt.p)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("relImportPath(%q, %q) = %q, want an error", tt.root, tt.p, rel)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("relImportPath(%q, %q): %v", tt.root, tt.p, err)
+			continue
+		}
+		if rel != tt.rel {
+			t.Errorf("relImportPath(%q, %q) = %q, want %q", tt.root, tt.p, rel, tt.rel)
+		}
+	}
+}
+
+func TestSplitImportVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		paths   []string
+		version string
+		wantErr bool
+	}{
+		{
+			name:  "no version",
+			args:  []string{"github.com/a/foo/cmd/foo"},
+			paths: []string{"github.com/a/foo/cmd/foo"},
+		},
+		{
+			name:    "version",
+			args:    []string{"github.com/a/foo/cmd/foo@v1.2.3"},
+			paths:   []string{"github.com/a/foo/cmd/foo"},
+			version: "v1.2.3",
+		},
+		{
+			name:    "latest",
+			args:    []string{"github.com/a/foo/cmd/foo@latest"},
+			paths:   []string{"github.com/a/foo/cmd/foo"},
+			version: "latest",
+		},
+		{
+			name:    "version of one of them",
+			args:    []string{"github.com/a/foo/cmd/foo@v1.2.3", "github.com/a/foo/cmd/bar"},
+			paths:   []string{"github.com/a/foo/cmd/foo", "github.com/a/foo/cmd/bar"},
+			version: "v1.2.3",
+		},
+		{
+			name:    "same versions",
+			args:    []string{"github.com/a/foo/cmd/foo@v1.2.3", "github.com/a/foo/cmd/bar@v1.2.3"},
+			paths:   []string{"github.com/a/foo/cmd/foo", "github.com/a/foo/cmd/bar"},
+			version: "v1.2.3",
+		},
+		{
+			name:    "different versions",
+			args:    []string{"github.com/a/foo/cmd/foo@v1.2.3", "github.com/a/foo/cmd/bar@v1.2.4"},
+			wantErr: true,
+		},
+		{
+			name:    "not a module version",
+			args:    []string{"github.com/a/foo/cmd/foo@1.2.3"},
+			wantErr: true,
+		},
+		{
+			name:    "branch",
+			args:    []string{"github.com/a/foo/cmd/foo@master"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, version, err := splitImportVersions(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %q, %q, want an error", paths, version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(paths, tt.paths) || version != tt.version {
+				t.Errorf("got %q, %q, want %q, %q", paths, version, tt.paths, tt.version)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "format", opts: Options{Format: "yaml"}},
+		{name: "protocol", opts: Options{Protocol: "ftp"}},
+		{name: "pkgrel", opts: Options{PkgRel: -3}},
+		{name: "epoch", opts: Options{Epoch: -1}},
+		{name: "timeout", opts: Options{Timeout: -time.Second}},
+		{name: "backup", opts: Options{Backup: []string{"/etc/foo.conf"}}},
+		{name: "install", opts: Options{Install: "../foo.install"}},
+		{name: "pkgver", opts: Options{PkgVer: "1.2-3"}},
+		{name: "branch", opts: Options{Branch: "-main"}},
+		{name: "dir", opts: Options{Dir: "a b/c"}},
+		{name: "dir ..", opts: Options{Dir: ".."}},
+		{name: "bindir", opts: Options{BinDir: "usr/local/bin"}},
+		{name: "changelog", opts: Options{Changelog: "doc/changelog"}},
+		{name: "cgo", opts: Options{CGO: "yes"}},
+		{name: "go111module", opts: Options{GO111Module: "yes"}},
+		{name: "gotoolchain", opts: Options{GoToolchain: "go1.21 local"}},
+		{name: "guidelines", opts: Options{Guidelines: true, CGO: "off"}},
+		{name: "arch", opts: Options{Arch: []string{"any", "x86_64"}}},
+		{name: "systemd-type", opts: Options{ServiceType: "daemon"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); !errors.As(err, new(IncorrectUsageError)) {
+				t.Errorf("got %v, want IncorrectUsageError", err)
+			}
+		})
+	}
+
+	if err := (Options{}).Validate(); err != nil {
+		t.Errorf("the defaults: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package pkgbuild
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/tools/go/vcs"
+)
+
+// runner runs the external commands inspecting the repository: the command
+// lines of the VCS, like cloning it, and the script printing the version. The
+// Generator uses execRunner, running the real commands, but it can be replaced
+// with the fake one, populating the directory with a canned history instead,
+// to inspect the repositories without the VCS and the network.
+type runner interface {
+	// runVCS runs the command line of the VCS like VCS.CreateCmd in dir,
+	// replacing "{key}" with the value given as the pairs in keyval.
+	runVCS(ctx context.Context, v *vcs.Cmd, dir, cmdline string, keyval ...string) error
+	// runScript runs the shell script in dir and returns its output.
+	runScript(ctx context.Context, dir, script string) ([]byte, error)
+}
+
+// execRunner is the runner running the real commands. Unlike the methods of
+// vcs.Cmd, they are aborted when ctx is done.
+type execRunner struct{}
+
+func (execRunner) runVCS(ctx context.Context, v *vcs.Cmd, dir, cmdline string, keyval ...string) error {
+	args := strings.Fields(cmdline)
+	for i := range args {
+		for j := 0; j+1 < len(keyval); j += 2 {
+			args[i] = strings.Replace(args[i], "{"+keyval[j]+"}", keyval[j+1], -1)
+		}
+	}
+
+	logger.debugf("running in %s: %s %s", dir, v.Cmd, strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, v.Cmd, args...)
+	cmd.Dir = dir
+	cmd.Env = vcsEnv()
+	out, err := cmd.CombinedOutput()
+	logger.debugf("%s", out)
+	if err != nil {
+		if !logger.enabled(levelDebug) {
+			os.Stderr.Write(out)
+		}
+		return vcsError(fmt.Errorf("%s %s: %w", v.Cmd, strings.Join(args, " "), err), out)
+	}
+	return nil
+}
+
+func (execRunner) runScript(ctx context.Context, dir, script string) ([]byte, error) {
+	logger.debugf("running in %s: bash -c %q", dir, script)
+	cmd := exec.CommandContext(ctx, "bash", "-c", script)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	logger.debugf("stdout: %s", out)
+	if stderr.Len() > 0 {
+		logger.debugf("stderr: %s", stderr.Bytes())
+	}
+	if err != nil {
+		if !logger.enabled(levelDebug) {
+			os.Stderr.Write(stderr.Bytes())
+		}
+		return nil, err
+	}
+	return out, nil
+}
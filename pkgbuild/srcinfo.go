@@ -0,0 +1,83 @@
+package pkgbuild
+
+import (
+	"io"
+	"text/template"
+)
+
+var srcinfoTmpl = template.Must(template.New(".SRCINFO").Parse(`pkgbase = {{or .PkgBase .PkgName}}
+{{- if .PkgDesc}}
+	pkgdesc = {{.PkgDesc}}
+{{- end}}
+	pkgver = {{.PkgVer}}
+	pkgrel = {{.PkgRel}}
+{{- if .Epoch}}
+	epoch = {{.Epoch}}
+{{- end}}
+	url = {{.Repo}}
+{{- range .Arch}}
+	arch = {{.}}
+{{- end}}
+{{- range .License}}
+	license = {{.}}
+{{- end}}
+{{- range .Groups}}
+	groups = {{.}}
+{{- end}}
+	makedepends = {{.GoPkg}}
+{{- range .MakeDepends}}
+	makedepends = {{.}}
+{{- end}}
+{{- range .Depends}}
+	depends = {{.}}
+{{- end}}
+{{- range .OptDepends}}
+	optdepends = {{.}}
+{{- end}}
+{{- range .Provides}}
+	provides = {{.}}
+{{- end}}
+{{- range .Conflicts}}
+	conflicts = {{.}}
+{{- end}}
+{{- range .Replaces}}
+	replaces = {{.}}
+{{- end}}
+{{- range .Backup}}
+	backup = {{.}}
+{{- end}}
+{{- if .Install}}
+	install = {{.Install}}
+{{- end}}
+{{- if .Changelog}}
+	changelog = {{.Changelog}}
+{{- end}}
+	source = {{if .NamedSource}}{{.Dir}}::{{end}}{{.Source}}
+{{- if .Service}}
+	source = {{.Service}}
+{{- end}}
+{{- range .Sha256Sums}}
+	sha256sums = {{.}}
+{{- end}}
+{{- range .SplitPkgs}}
+
+pkgname = {{.Name}}
+{{- range .Provides}}
+	provides = {{.}}
+{{- end}}
+{{- range .Conflicts}}
+	conflicts = {{.}}
+{{- end}}
+{{- range .Replaces}}
+	replaces = {{.}}
+{{- end}}
+{{- else}}
+
+pkgname = {{.PkgName}}
+{{- end}}
+`))
+
+// WriteSrcinfo writes .SRCINFO of the package to w.
+func WriteSrcinfo(w io.Writer, data TmplData) error {
+	return srcinfoTmpl.Execute(w, data)
+}
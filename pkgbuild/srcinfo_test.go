@@ -0,0 +1,36 @@
+package pkgbuild
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteSrcinfoChangelog(t *testing.T) {
+	data := TmplData{
+		PkgName:   "foo-git",
+		PkgVer:    "r1.abc1234",
+		PkgRel:    1,
+		Install:   "foo.install",
+		Changelog: "foo.changelog",
+		Source:    "git+https://github.com/a/foo",
+	}
+	var buf bytes.Buffer
+	if err := WriteSrcinfo(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	// In the order of makepkg --printsrcinfo.
+	want := "\tinstall = foo.install\n\tchangelog = foo.changelog\n\tsource = git+https://github.com/a/foo\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf(".SRCINFO doesn't contain %q:\n%s", want, buf.String())
+	}
+
+	data.Changelog = ""
+	buf.Reset()
+	if err := WriteSrcinfo(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "changelog") {
+		t.Errorf(".SRCINFO contains changelog without it:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,56 @@
+package pkgbuild
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+)
+
+// serviceTypes is the values of Type= of the systemd service.
+var serviceTypes = map[string]bool{
+	"simple":  true,
+	"exec":    true,
+	"forking": true,
+	"oneshot": true,
+	"dbus":    true,
+	"notify":  true,
+	"idle":    true,
+}
+
+// ServiceUnit returns the content of the systemd service scaffolded for the
+// first binary of the package.
+func ServiceUnit(data TmplData, opts Options) string {
+	desc := opts.ServiceDesc
+	if desc == "" {
+		desc = data.PkgDesc
+	}
+	if desc == "" {
+		desc = data.Binaries[0].Name
+	}
+	serviceType := opts.ServiceType
+	if serviceType == "" {
+		serviceType = "simple"
+	}
+	binDir := data.BinDir
+	if binDir == "" {
+		binDir = "/usr/bin"
+	}
+	return fmt.Sprintf(`[Unit]
+Description=%s
+After=network.target
+
+[Service]
+Type=%s
+ExecStart=%s
+
+[Install]
+WantedBy=multi-user.target
+`, desc, serviceType, path.Join(binDir, data.Binaries[0].Name))
+}
+
+// serviceSHA256 returns the checksum of the service unit.
+func serviceSHA256(unit string) string {
+	sum := sha256.Sum256([]byte(unit))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,218 @@
+package pkgbuild
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// updatedFields is the fields of the existing PKGBUILD rewritten by Update.
+// The others are kept as they are, with the customizations by the user.
+var updatedFields = []string{"_pkgname", "pkgver", "pkgrel", "source", "sha256sums"}
+
+// firstElementRe matches the first element of the array, like "'foo'" of
+// "('foo' 'bar')".
+var firstElementRe = regexp.MustCompile(`^\(\s*('[^']*'|"[^"]*"|[^\s)]+)`)
+
+// pkgbuildField is a variable assigned at the top level of the PKGBUILD.
+type pkgbuildField struct {
+	// value is the text after "=", including the newlines of the multi-line
+	// array.
+	value string
+	// start and end is the range of the lines of the assignment.
+	start, end int
+}
+
+var assignmentRe = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+
+// parsePKGBUILDFields reads the variables assigned at the top level of the
+// PKGBUILD split into lines. It's not a shell parser: the assignments must
+// start at the beginning of the lines, and the multi-line arrays have to end
+// with ")" at the end of the line, as makepkg and this command write them. The
+// first assignment wins if the variable is assigned twice.
+func parsePKGBUILDFields(lines []string) map[string]pkgbuildField {
+	fields := make(map[string]pkgbuildField)
+	for i := 0; i < len(lines); i++ {
+		m := assignmentRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		f := pkgbuildField{value: m[2], start: i, end: i + 1}
+		if strings.HasPrefix(m[2], "(") {
+			for f.end < len(lines) && !strings.HasSuffix(strings.TrimSpace(lines[f.end-1]), ")") {
+				f.value += "\n" + lines[f.end]
+				f.end++
+			}
+		}
+		if _, ok := fields[m[1]]; !ok {
+			fields[m[1]] = f
+		}
+	}
+	return fields
+}
+
+// unquote returns the value of the field without the quotes, for the simple
+// values like pkgver and pkgrel.
+func (f pkgbuildField) unquote() string {
+	return strings.Trim(f.value, `'"`)
+}
+
+// replaceFirstElement replaces the first element of the array old with the one
+// of the array new.
+func replaceFirstElement(old, new string) string {
+	om := firstElementRe.FindStringSubmatchIndex(old)
+	nm := firstElementRe.FindStringSubmatch(new)
+	if om == nil || nm == nil {
+		return new
+	}
+	return old[:om[2]] + nm[1] + old[om[3]:]
+}
+
+// Update refreshes the PKGBUILD old generated before for the import paths, and
+// returns the new one. The version and the source are re-resolved, and only the
+// lines of them are rewritten, keeping the others like the comments and the
+// functions edited by the user. The release package has to be given the new tag
+// by Options.Tag.
+//
+// pkgrel is set to pkgRel if it's positive. Otherwise, it's reset to 1 if the
+// version is changed, and kept if not.
+func (g *Generator) Update(ctx context.Context, old []byte, importPaths []string, pkgRel int) ([]byte, error) {
+	lines := strings.Split(string(old), "\n")
+	oldFields := parsePKGBUILDFields(lines)
+	if isReleasePKGBUILD(old) && g.opts.Tag == "" {
+		return nil, IncorrectUsageError{errors.New("specify the new tag with -tag to update the release package")}
+	}
+	data, err := g.resolveExisting(ctx, oldFields, importPaths)
+	if err != nil {
+		return nil, err
+	}
+	if pkgRel > 0 {
+		data.PkgRel = pkgRel
+	} else if data.PkgVer == oldFields["pkgver"].unquote() {
+		if n, err := strconv.Atoi(oldFields["pkgrel"].unquote()); err == nil {
+			data.PkgRel = n
+		}
+	} else {
+		data.PkgRel = 1
+	}
+
+	var buf bytes.Buffer
+	if err := g.tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("could not render the PKGBUILD: %w", err)
+	}
+	newFields := parsePKGBUILDFields(strings.Split(buf.String(), "\n"))
+
+	// The fields to be replaced, by the first line of them.
+	replaced := make(map[int]pkgbuildField)
+	for _, name := range updatedFields {
+		o, ok := oldFields[name]
+		n, nok := newFields[name]
+		if !ok || !nok || o.value == n.value {
+			continue
+		}
+		value := n.value
+		if name == "source" || name == "sha256sums" {
+			// Only the source of the repository is replaced, keeping the
+			// files added by the user, like the patches.
+			value = replaceFirstElement(o.value, n.value)
+			if value == o.value {
+				continue
+			}
+		}
+		logger.debugf("updating %s: %s -> %s", name, o.value, value)
+		o.value = name + "=" + value
+		replaced[o.start] = o
+	}
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		if f, ok := replaced[i]; ok {
+			out = append(out, f.value)
+			i = f.end - 1
+			continue
+		}
+		out = append(out, lines[i])
+	}
+
+	b := []byte(strings.Join(out, "\n"))
+	if g.opts.Check {
+		// The install script and the changelog of the PKGBUILD are kept.
+		if err := checkPKGBUILD(ctx, b, oldFields["install"].unquote(), oldFields["changelog"].unquote()); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// isReleasePKGBUILD returns whether the PKGBUILD b is for the release, without
+// pkgver().
+func isReleasePKGBUILD(b []byte) bool {
+	return !bytes.Contains(b, []byte("\npkgver()"))
+}
+
+// resolveExisting resolves the values for the existing PKGBUILD with the
+// fields, for the import paths, or the one read from url if they are empty.
+// The options kept from the PKGBUILD are set to the copy of the generator, not
+// to be carried over to the next generations.
+func (g *Generator) resolveExisting(ctx context.Context, fields map[string]pkgbuildField, importPaths []string) (TmplData, error) {
+	ng := *g
+	g = &ng
+	if _, ok := fields["pkgver"]; !ok {
+		return TmplData{}, errors.New("pkgver is not found in the PKGBUILD")
+	}
+	if len(importPaths) == 0 {
+		url, ok := fields["url"]
+		if !ok {
+			return TmplData{}, IncorrectUsageError{errors.New("specify import path, since url is not found in the PKGBUILD")}
+		}
+		importPaths = []string{strings.TrimSuffix(regexp.MustCompile(`^[a-z+]+://`).ReplaceAllString(url.unquote(), ""), ".git")}
+	}
+	// Keep the name not to resolve it into another one.
+	if g.opts.PkgName == "" {
+		if f, ok := fields["pkgbase"]; ok {
+			g.opts.PkgName = f.unquote()
+		} else if f, ok := fields["pkgname"]; ok && !strings.HasPrefix(f.value, "(") {
+			g.opts.PkgName = f.unquote()
+		}
+	}
+	// Keep tracking the branch, or pinning the commit until another one is
+	// given.
+	if g.opts.Branch == "" && g.opts.Commit == "" {
+		if m := refFragmentRe.FindStringSubmatch(fields["source"].value); m != nil {
+			if m[1] == "branch" {
+				g.opts.Branch = m[2]
+			} else {
+				g.opts.Commit = m[2]
+			}
+		}
+	}
+	return g.resolveData(ctx, importPaths)
+}
+
+// refFragmentRe matches the fragment of the git source of the branch or the
+// commit, like "#branch=next".
+var refFragmentRe = regexp.MustCompile(`#(branch|commit)=([^'"\s)]+)`)
+
+// CheckUpdate resolves the version of the package of the existing PKGBUILD old
+// for the import paths, read from url if they are empty like Update, and
+// returns the version in it and the resolved one, without rewriting anything.
+// For the release package, the latest module version in the module proxies is
+// looked up unless Options.Tag is given, as Options.PkgVerFromGoMod does.
+func (g *Generator) CheckUpdate(ctx context.Context, old []byte, importPaths []string) (current, latest string, err error) {
+	fields := parsePKGBUILDFields(strings.Split(string(old), "\n"))
+	if isReleasePKGBUILD(old) && g.opts.Tag == "" {
+		// Only for this check, not to pin the next generations to the
+		// release.
+		ng := *g
+		ng.opts.PkgVerFromGoMod = true
+		g = &ng
+	}
+	data, err := g.resolveExisting(ctx, fields, importPaths)
+	if err != nil {
+		return "", "", err
+	}
+	return fields["pkgver"].unquote(), data.PkgVer, nil
+}
@@ -0,0 +1,71 @@
+package pkgbuild
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestUpdateKeepsOptions(t *testing.T) {
+	dir, cleanup := newLocalModule(t, map[string]string{
+		"go.mod":          "module example.com/foo\n",
+		"cmd/foo/main.go": "package main\n\nfunc main() {}\n",
+	})
+	defer cleanup()
+	args := []string{dir + "/cmd/foo"}
+
+	g := NewGenerator(Options{Quiet: true}, nil, nil)
+	opts := g.opts
+	for _, name := range []string{"first-git", "second-git"} {
+		old, err := GenerateToString(context.Background(), Options{Quiet: true, PkgName: name}, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := g.Update(context.Background(), []byte(old), args, 0); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(g.opts, opts) {
+			t.Errorf("the options are changed by Update of %s: %+v", name, g.opts)
+		}
+	}
+
+	data, err := g.Resolve(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.PkgName != "foo-git" {
+		t.Errorf("PkgName %q after Update, want the default foo-git", data.PkgName)
+	}
+}
+
+func TestCheckUpdateKeepsOptions(t *testing.T) {
+	dir, cleanup := newLocalModule(t, map[string]string{
+		"go.mod":          "module example.com/foo\n",
+		"cmd/foo/main.go": "package main\n\nfunc main() {}\n",
+	})
+	defer cleanup()
+	args := []string{dir + "/cmd/foo"}
+	// The latest release is not found without the module proxies.
+	defer os.Setenv("GOPROXY", os.Getenv("GOPROXY"))
+	os.Setenv("GOPROXY", "off")
+
+	g := NewGenerator(Options{Quiet: true}, nil, nil)
+	opts := g.opts
+	release := "pkgname=foo\npkgver=1.0.0\npkgrel=1\nsource=('foo-1.0.0.tar.gz::https://example.com/foo/v1.0.0.tar.gz')\n"
+	if _, _, err := g.CheckUpdate(context.Background(), []byte(release), args); err == nil {
+		t.Error("CheckUpdate succeeded without the module proxies")
+	}
+	if !reflect.DeepEqual(g.opts, opts) {
+		t.Errorf("the options are changed by CheckUpdate: %+v", g.opts)
+	}
+
+	// The VCS package is still resolved to the version of the history.
+	data, err := g.Resolve(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Tag != "" || data.PkgName != "foo-git" {
+		t.Errorf("resolved to the release %q of %s after CheckUpdate", data.Tag, data.PkgName)
+	}
+}
@@ -0,0 +1,191 @@
+package pkgbuild
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/tools/go/vcs"
+)
+
+// vcsBackend is the VCS specific part of PKGBUILD.
+type vcsBackend interface {
+	// source returns the source of the repository in the source array. The
+	// protocol is the one given by -protocol.
+	source(repoRoot *vcs.RepoRoot, protocol string) string
+	// pkgVerCmd returns the shell script printing the version of the
+	// checked out repository. It is the body of pkgver(), and also used to
+	// detect the current version.
+	pkgVerCmd() string
+	// makeDepends returns the packages needed by makepkg to fetch the
+	// source.
+	makeDepends() []string
+	// clone clones the repository of v at repo into the empty directory dir,
+	// with r.
+	clone(ctx context.Context, r runner, v *vcs.Cmd, repo, dir string) error
+}
+
+// vcsBackends holds the supported VCSs, keyed by the command name like "git".
+// The name is also the suffix of the package name and the prefix of the source.
+var vcsBackends = map[string]vcsBackend{
+	"git":    gitBackend{},
+	"hg":     hgBackend{},
+	"svn":    svnBackend{},
+	"bzr":    bzrBackend{},
+	"fossil": fossilBackend{},
+}
+
+// createCmdClone is embedded in the backends cloning the repository with
+// VCS.CreateCmd.
+type createCmdClone struct{}
+
+func (createCmdClone) clone(ctx context.Context, r runner, v *vcs.Cmd, repo, dir string) error {
+	return r.runVCS(ctx, v, ".", v.CreateCmd, "dir", dir, "repo", repo)
+}
+
+type gitBackend struct{ createCmdClone }
+
+func (gitBackend) source(repoRoot *vcs.RepoRoot, protocol string) string {
+	return gitSource(protocol, repoRoot.Root)
+}
+
+// pkgVerCmd returns the script converting the output of git describe like
+// "v1.2.3-rc1-5-gabc1234" into "1.2.3rc1.r5.gabc1234", in the same way as
+// tagVersion.
+func (gitBackend) pkgVerCmd() string {
+	return strings.TrimSpace(`
+( set -o pipefail
+  git describe --long --tags 2>/dev/null |
+    sed 's/^[^0-9]*//;s/-\([0-9]*\)-g\([0-9a-f]*\)$/.r\1.g\2/;s/-\([[:alpha:]]\)/\1/g;s|[-:/]|.|g' ||
+  printf "r%s.%s" "$(git rev-list --count HEAD)" "$(git rev-parse --short HEAD)"
+)
+`)
+}
+
+func (gitBackend) makeDepends() []string {
+	return []string{"git"}
+}
+
+// gitSource returns the source of the git repository at root, fetched with the
+// protocol.
+func gitSource(protocol, root string) string {
+	if protocol == "ssh" {
+		return fmt.Sprintf("git+ssh://git@%s", root)
+	}
+	return fmt.Sprintf("git+%s://%s", protocol, root)
+}
+
+type hgBackend struct{ createCmdClone }
+
+func (hgBackend) source(repoRoot *vcs.RepoRoot, protocol string) string {
+	return "hg+" + repoRoot.Repo
+}
+
+func (hgBackend) pkgVerCmd() string {
+	return `printf "r%s.%s" "$(hg identify -n)" "$(hg identify -i)"`
+}
+
+func (hgBackend) makeDepends() []string {
+	return []string{"mercurial"}
+}
+
+type svnBackend struct{ createCmdClone }
+
+func (svnBackend) source(repoRoot *vcs.RepoRoot, protocol string) string {
+	// makepkg fetches svn:// with Subversion without the prefix.
+	if strings.HasPrefix(repoRoot.Repo, "svn://") {
+		return repoRoot.Repo
+	}
+	return "svn+" + repoRoot.Repo
+}
+
+func (svnBackend) pkgVerCmd() string {
+	return `printf "r%s" "$(svnversion | tr -d 'A-z')"`
+}
+
+func (svnBackend) makeDepends() []string {
+	return []string{"subversion"}
+}
+
+type bzrBackend struct{ createCmdClone }
+
+func (bzrBackend) source(repoRoot *vcs.RepoRoot, protocol string) string {
+	return "bzr+" + repoRoot.Repo
+}
+
+func (bzrBackend) pkgVerCmd() string {
+	return `printf "r%s" "$(bzr revno)"`
+}
+
+func (bzrBackend) makeDepends() []string {
+	return []string{"bzr"}
+}
+
+// vcsFossil is the Fossil, which golang.org/x/tools/go/vcs doesn't know.
+var vcsFossil = &vcs.Cmd{
+	Name:       "Fossil",
+	Cmd:        "fossil",
+	TagSyncCmd: "update {tag}",
+}
+
+type fossilBackend struct{}
+
+func (fossilBackend) source(repoRoot *vcs.RepoRoot, protocol string) string {
+	return "fossil+" + repoRoot.Repo
+}
+
+func (fossilBackend) pkgVerCmd() string {
+	return `printf "r%s.%s" "$(fossil sql "SELECT count(*) FROM event WHERE type = 'ci'")" "$(fossil info | sed -n 's/^checkout: *\([0-9a-f]\{10\}\).*/\1/p')"`
+}
+
+func (fossilBackend) makeDepends() []string {
+	return []string{"fossil"}
+}
+
+// clone clones the repository into the file in dir and opens it, as makepkg
+// does. fossil clone can't do both at once in the older versions.
+func (fossilBackend) clone(ctx context.Context, r runner, v *vcs.Cmd, repo, dir string) error {
+	if err := r.runVCS(ctx, v, dir, "clone {repo} .fossil", "repo", repo); err != nil {
+		return err
+	}
+	return r.runVCS(ctx, v, dir, "open --force .fossil")
+}
+
+// vcsSource returns the source of the repository at the URL for the VCS, like
+// "git+https://...", unless the URL already has the prefix.
+func vcsSource(cmd, repo string) string {
+	if strings.HasPrefix(repo, cmd+"+") || strings.HasPrefix(repo, cmd+"://") {
+		return repo
+	}
+	return cmd + "+" + repo
+}
+
+// trimVCSSuffix returns the package name without the VCS suffix like "-git",
+// and whether the package name has the suffix.
+func trimVCSSuffix(pkgName string) (string, bool) {
+	for name := range vcsBackends {
+		s := "-" + name
+		if strings.HasSuffix(pkgName, s) && pkgName != s {
+			return strings.TrimSuffix(pkgName, s), true
+		}
+	}
+	return pkgName, false
+}
+
+// vcsCloneDir returns the directory the VCS source is cloned into by makepkg
+// without the "name::" prefix, which is the last element of the URL without
+// the fragment, the query and ".git" for git.
+func vcsCloneDir(source string) string {
+	s := source
+	if i := strings.IndexAny(s, "#?"); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSuffix(s, "/")
+	s = s[strings.LastIndex(s, "/")+1:]
+	if strings.HasPrefix(source, "git+") || strings.HasPrefix(source, "git://") {
+		if i := strings.Index(s, ".git"); i >= 0 {
+			s = s[:i]
+		}
+	}
+	return s
+}
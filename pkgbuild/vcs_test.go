@@ -0,0 +1,30 @@
+package pkgbuild
+
+import "testing"
+
+func TestVCSCloneDir(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"git+https://github.com/a/foo", "foo"},
+		{"git+https://github.com/a/foo.git", "foo"},
+		{"git+https://github.com/a/foo/", "foo"},
+		{"git+https://github.com/a/foo#branch=next", "foo"},
+		{"git+https://github.com/a/foo.git#commit=abc1234", "foo"},
+		{"git+ssh://git@github.com/a/foo", "foo"},
+		{"git://github.com/a/foo.git", "foo"},
+		// The mirrors named differently from the import path.
+		{"git+https://example.com/mirrors/Foo-mirror.git", "Foo-mirror"},
+		{"git+https://git.example.com/m/foo.git?signed", "foo"},
+		// makepkg strips ".git" and the rest for git.
+		{"git+https://github.com/a/foo.github.io", "foo"},
+		{"hg+https://hg.example.com/foo.git", "foo.git"},
+		{"svn+https://svn.example.com/foo/trunk", "trunk"},
+	}
+	for _, tt := range tests {
+		if got := vcsCloneDir(tt.source); got != tt.want {
+			t.Errorf("vcsCloneDir(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// writeServiceUnit scaffolds the systemd service named name next to the
+// PKGBUILD written to pkgbuildPath, like writeInstallScript. If the existing one
+// differs from unit, the checksum in the PKGBUILD has to be updated.
+func writeServiceUnit(pkgbuildPath, name, unit string) error {
+	if pkgbuildPath != "-" {
+		b, err := ioutil.ReadFile(filepath.Join(filepath.Dir(pkgbuildPath), name))
+		if err == nil && !bytes.Equal(b, []byte(unit)) {
+			fmt.Fprintf(os.Stderr, "warning: %s already exists and is kept. Update the checksum with updpkgsums.\n", name)
+		}
+	}
+	return scaffoldFile(pkgbuildPath, name, unit)
+}
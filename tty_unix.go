@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "os"
+
+// openTTY opens the terminal to read the answers from and write the prompts
+// to.
+func openTTY() (in *os.File, out *os.File, err error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tty, tty, nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import "os"
+
+// openTTY opens the console to read the answers from and write the prompts
+// to.
+func openTTY() (in *os.File, out *os.File, err error) {
+	in, err = os.OpenFile("CONIN$", os.O_RDWR, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err = os.OpenFile("CONOUT$", os.O_RDWR, 0644)
+	if err != nil {
+		in.Close()
+		return nil, nil, err
+	}
+	return in, out, nil
+}
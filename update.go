@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/acomagu/genpkgbuild-go/pkgbuild"
+)
+
+// runUpdate updates the PKGBUILD given by -update for the import paths, which
+// may be empty to read it from the PKGBUILD.
+func runUpdate(ctx context.Context, g *pkgbuild.Generator, args []string, opts options) error {
+	opts.update = outputPath(opts.update, "pkgbuild")
+	old, err := ioutil.ReadFile(opts.update)
+	if err != nil {
+		return err
+	}
+
+	pkgRel := 0
+	if opts.pkgRelGiven {
+		pkgRel = opts.PkgRel
+	}
+	b, err := g.Update(ctx, old, args, pkgRel)
+	if err != nil {
+		return fmt.Errorf("could not update %s: %w", opts.update, err)
+	}
+
+	if opts.output == "-" || opts.dryRun {
+		_, err := os.Stdout.Write(b)
+		return err
+	}
+	return writeFile(opts.update, b, true)
+}
+
+// errUpdateAvailable is returned by runCheckUpdates if the update is
+// available, to exit with 2.
+var errUpdateAvailable = errors.New("update available")
+
+// runCheckUpdates prints whether the version resolved for the PKGBUILD given
+// by -check-updates is different from its pkgver.
+func runCheckUpdates(ctx context.Context, g *pkgbuild.Generator, args []string, opts options) error {
+	opts.checkUpdates = outputPath(opts.checkUpdates, "pkgbuild")
+	b, err := ioutil.ReadFile(opts.checkUpdates)
+	if err != nil {
+		return err
+	}
+
+	current, latest, err := g.CheckUpdate(ctx, b, args)
+	if err != nil {
+		return fmt.Errorf("could not check the updates of %s: %w", opts.checkUpdates, err)
+	}
+	if current == latest {
+		fmt.Printf("%s: up to date (%s)\n", opts.checkUpdates, current)
+		return nil
+	}
+	fmt.Printf("%s: update available (%s -> %s)\n", opts.checkUpdates, current, latest)
+	return errUpdateAvailable
+}